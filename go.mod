@@ -5,8 +5,10 @@ go 1.18
 require (
 	github.com/gomodule/redigo v1.8.2
 	github.com/launchdarkly/go-sdk-common/v3 v3.1.0
+	github.com/launchdarkly/go-server-sdk-evaluation/v3 v3.0.0
 	github.com/launchdarkly/go-server-sdk/v7 v7.0.0
 	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.0
 )
 
 require (
@@ -19,7 +21,6 @@ require (
 	github.com/launchdarkly/go-jsonstream/v3 v3.0.0 // indirect
 	github.com/launchdarkly/go-sdk-events/v3 v3.0.0 // indirect
 	github.com/launchdarkly/go-semver v1.0.2 // indirect
-	github.com/launchdarkly/go-server-sdk-evaluation/v3 v3.0.0 // indirect
 	github.com/launchdarkly/go-test-helpers/v2 v2.3.1 // indirect
 	github.com/launchdarkly/go-test-helpers/v3 v3.0.2 // indirect
 	github.com/mailru/easyjson v0.7.6 // indirect
@@ -27,5 +28,4 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/exp v0.0.0-20220823124025-807a23277127 // indirect
 	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e // indirect
-	gopkg.in/yaml.v3 v3.0.0 // indirect
 )
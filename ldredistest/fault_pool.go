@@ -0,0 +1,363 @@
+package ldredistest
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+)
+
+// ErrInjectedFault is the default error returned by operations that fail because of a
+// [FaultRule].
+var ErrInjectedFault = errors.New("ldredistest: injected fault")
+
+// ConnPool is the interface of a Redis connection pool that can be wrapped by [FaultPool]. It has
+// the same methods as the Pool interface of the ldredis package, so it is satisfied by
+// *redigo.Pool, by [Pool], and by any custom pool implementation.
+type ConnPool interface {
+	Get() r.Conn
+	Close() error
+}
+
+// FaultPool is a connection pool wrapper that injects failures into the operations of another
+// pool. It implements the Pool interface of the ldredis package, so it can be passed to
+// StoreBuilder.PoolInterface.
+//
+// Faults are described by rules that are added with [FaultPool.OnGet] and [FaultPool.OnCommand].
+// For instance, to make the next two HGET commands fail, and to make one transaction fail as if a
+// watched key had been modified:
+//
+//	faults := ldredistest.NewFaultPool(server.NewPool())
+//	faults.OnCommand("HGET").Times(2).Fail(nil)
+//	faults.OnCommand("EXEC").Times(1).AbortExec()
+//	store := ldredis.DataStore().PoolInterface(faults)
+//
+// Rules are checked in the order they were added, and the first rule that matches is used. Rules
+// can be added or cleared at any time, including while the pool is in use.
+type FaultPool struct {
+	target       ConnPool
+	lock         sync.Mutex
+	getRules     []*FaultRule
+	commandRules []*FaultRule
+}
+
+// FaultRule describes a failure that a [FaultPool] injects into matching operations. The methods
+// of FaultRule modify the rule in place and return it, so they can be chained.
+//
+// A rule that has no Delay, Fail, AbortExec, or DropConnection behavior still counts matching
+// operations for the purposes of After and Times, but does not change them.
+type FaultRule struct {
+	pool      *FaultPool
+	command   string
+	skip      int
+	remaining int
+	delay     time.Duration
+	fail      bool
+	err       error
+	abortExec bool
+	drop      bool
+}
+
+// NewFaultPool creates a FaultPool that wraps the specified pool. Until rules are added, all
+// operations are passed through to the wrapped pool unchanged.
+func NewFaultPool(target ConnPool) *FaultPool {
+	return &FaultPool{target: target}
+}
+
+// OnGet adds a rule that applies when a connection is obtained from the pool. Use
+// [FaultRule.Fail] to simulate a connection error, or [FaultRule.Delay] to simulate slow
+// connection setup.
+func (p *FaultPool) OnGet() *FaultRule {
+	rule := &FaultRule{pool: p, remaining: -1}
+	p.lock.Lock()
+	p.getRules = append(p.getRules, rule)
+	p.lock.Unlock()
+	return rule
+}
+
+// OnCommand adds a rule that applies to a Redis command with the specified name, such as "HGET".
+// Command names are case-insensitive. The name "*" matches every command.
+func (p *FaultPool) OnCommand(name string) *FaultRule {
+	rule := &FaultRule{pool: p, command: strings.ToUpper(name), remaining: -1}
+	p.lock.Lock()
+	p.commandRules = append(p.commandRules, rule)
+	p.lock.Unlock()
+	return rule
+}
+
+// Clear removes all rules.
+func (p *FaultPool) Clear() {
+	p.lock.Lock()
+	p.getRules, p.commandRules = nil, nil
+	p.lock.Unlock()
+}
+
+// Get obtains a connection from the wrapped pool, subject to any rules added with OnGet.
+func (p *FaultPool) Get() r.Conn {
+	if rule, ok := p.match(true, ""); ok {
+		if rule.delay > 0 {
+			time.Sleep(rule.delay)
+		}
+		if rule.fail {
+			return errorConn{rule.error()}
+		}
+	}
+	return &faultConn{pool: p, target: p.target.Get()}
+}
+
+// Close closes the wrapped pool.
+func (p *FaultPool) Close() error {
+	return p.target.Close()
+}
+
+// After specifies that the rule should ignore the first n matching operations.
+func (f *FaultRule) After(n int) *FaultRule {
+	f.pool.lock.Lock()
+	f.skip = n
+	f.pool.lock.Unlock()
+	return f
+}
+
+// Times specifies that the rule should apply to at most n matching operations, after which it has
+// no further effect. By default, a rule applies to every matching operation.
+func (f *FaultRule) Times(n int) *FaultRule {
+	f.pool.lock.Lock()
+	f.remaining = n
+	f.pool.lock.Unlock()
+	return f
+}
+
+// Delay specifies that each matching operation should be delayed by the specified duration before
+// it is performed. This can be combined with any other behavior.
+func (f *FaultRule) Delay(delay time.Duration) *FaultRule {
+	f.pool.lock.Lock()
+	f.delay = delay
+	f.pool.lock.Unlock()
+	return f
+}
+
+// Fail specifies that each matching operation should fail with the specified error instead of
+// being performed. If err is nil, [ErrInjectedFault] is used.
+//
+// For commands that are pipelined with Send, only the reply for the matching command fails; the
+// other commands in the pipeline are performed normally. To simulate an error reply from the
+// Redis server, such as "LOADING", use a value of type redigo.Error.
+func (f *FaultRule) Fail(err error) *FaultRule {
+	f.pool.lock.Lock()
+	f.fail, f.err = true, err
+	f.pool.lock.Unlock()
+	return f
+}
+
+// AbortExec specifies that a matching EXEC command should discard the transaction and return a
+// nil reply, which is how Redis reports that a watched key was modified by another client.
+func (f *FaultRule) AbortExec() *FaultRule {
+	f.pool.lock.Lock()
+	f.abortExec = true
+	f.pool.lock.Unlock()
+	return f
+}
+
+// DropConnection specifies that the connection should be closed when a matching command is
+// executed. The matching command, and every subsequent operation on the same connection, fails
+// with the error given to Fail, or [ErrInjectedFault] if none was given. If the connection was in
+// the middle of a transaction, the transaction is not executed.
+func (f *FaultRule) DropConnection() *FaultRule {
+	f.pool.lock.Lock()
+	f.drop = true
+	f.pool.lock.Unlock()
+	return f
+}
+
+func (f *FaultRule) error() error {
+	if f.err == nil {
+		return ErrInjectedFault
+	}
+	return f.err
+}
+
+// match finds the first rule that applies to an operation, updating its counters, and returns a
+// copy of it so that the caller does not need to hold the lock while using it.
+func (p *FaultPool) match(onGet bool, command string) (FaultRule, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	rules := p.commandRules
+	if onGet {
+		rules = p.getRules
+	}
+	for _, rule := range rules {
+		if rule.command != "*" && rule.command != command {
+			continue
+		}
+		if rule.remaining == 0 {
+			continue
+		}
+		if rule.skip > 0 {
+			rule.skip--
+			continue
+		}
+		if rule.remaining > 0 {
+			rule.remaining--
+		}
+		return *rule, true
+	}
+	return FaultRule{}, false
+}
+
+// pendingReply describes where the reply to a sent command will come from: either the wrapped
+// connection, or an injected failure.
+type pendingReply struct {
+	injected bool
+	reply    interface{}
+	err      error
+}
+
+// faultConn is the connection type returned by FaultPool.
+type faultConn struct {
+	pool    *FaultPool
+	target  r.Conn
+	pending []pendingReply
+	dropErr error
+}
+
+func (c *faultConn) Close() error {
+	if c.dropErr != nil {
+		return nil // the underlying connection was already closed
+	}
+	return c.target.Close()
+}
+
+func (c *faultConn) Err() error {
+	if c.dropErr != nil {
+		return c.dropErr
+	}
+	return c.target.Err()
+}
+
+func (c *faultConn) Send(commandName string, args ...interface{}) error {
+	if c.dropErr != nil {
+		return c.dropErr
+	}
+	name := strings.ToUpper(commandName)
+	rule, ok := c.pool.match(false, name)
+	if !ok {
+		if err := c.target.Send(commandName, args...); err != nil {
+			return err
+		}
+		c.pending = append(c.pending, pendingReply{})
+		return nil
+	}
+	if rule.delay > 0 {
+		time.Sleep(rule.delay)
+	}
+	switch {
+	case rule.drop:
+		c.dropErr = rule.error()
+		_ = c.target.Close()
+		return c.dropErr
+	case rule.fail:
+		err := rule.error()
+		c.pending = append(c.pending, pendingReply{injected: true, reply: asReply(err), err: err})
+		return nil
+	case rule.abortExec && name == "EXEC":
+		// Discard the transaction on the underlying connection, but report it as aborted.
+		if err := c.target.Send("DISCARD"); err != nil {
+			return err
+		}
+		c.pending = append(c.pending, pendingReply{injected: true})
+		return nil
+	}
+	if err := c.target.Send(commandName, args...); err != nil {
+		return err
+	}
+	c.pending = append(c.pending, pendingReply{})
+	return nil
+}
+
+func (c *faultConn) Flush() error {
+	if c.dropErr != nil {
+		return c.dropErr
+	}
+	return c.target.Flush()
+}
+
+func (c *faultConn) Receive() (interface{}, error) {
+	reply, _, err := c.receive()
+	return reply, err
+}
+
+// receive reads the next reply, and also reports whether it was an injected failure.
+func (c *faultConn) receive() (interface{}, bool, error) {
+	if c.dropErr != nil {
+		return nil, true, c.dropErr
+	}
+	if len(c.pending) == 0 {
+		reply, err := c.target.Receive()
+		return reply, false, err
+	}
+	p := c.pending[0]
+	c.pending = c.pending[1:]
+	if !p.injected {
+		reply, err := c.target.Receive()
+		return reply, false, err
+	}
+	if p.err == nil {
+		// This is an aborted EXEC; consume the reply to the DISCARD that replaced it.
+		if _, err := c.target.Receive(); err != nil {
+			return nil, false, err
+		}
+	}
+	return p.reply, true, p.err
+}
+
+// Do has the same semantics as the Do method of a Redigo connection: it sends the command, flushes
+// the output buffer, and reads all pending replies. It returns the reply to the last command, and
+// the first error if there was one. As with Redigo, a network error from the wrapped connection
+// ends the operation immediately, but an injected failure only affects its own command.
+func (c *faultConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if c.dropErr != nil {
+		return nil, c.dropErr
+	}
+	if commandName != "" {
+		if err := c.Send(commandName, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.target.Flush(); err != nil {
+		return nil, err
+	}
+	count := len(c.pending)
+	replies := make([]interface{}, 0, count)
+	var firstErr error
+	for i := 0; i < count; i++ {
+		reply, injected, err := c.receive()
+		if err != nil {
+			if _, isReplyErr := err.(r.Error); !isReplyErr && !injected {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		replies = append(replies, reply)
+	}
+	if commandName == "" {
+		return replies, nil
+	}
+	if len(replies) == 0 {
+		return nil, firstErr
+	}
+	return replies[len(replies)-1], firstErr
+}
+
+// asReply returns the value that Receive should return along with an injected error. Redis error
+// replies are returned as both the reply and the error, as Redigo does; other errors, which
+// represent network failures, have a nil reply.
+func asReply(err error) interface{} {
+	if e, ok := err.(r.Error); ok {
+		return e
+	}
+	return nil
+}
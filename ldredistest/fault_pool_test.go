@@ -0,0 +1,135 @@
+package ldredistest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultPoolPassesThroughWithoutRules(t *testing.T) {
+	faults := NewFaultPool(NewServer().NewPool())
+	c := faults.Get()
+	defer c.Close()
+
+	_, err := c.Do("SET", "k", "v")
+	require.NoError(t, err)
+	value, err := r.String(c.Do("GET", "k"))
+	require.NoError(t, err)
+	assert.Equal(t, "v", value)
+}
+
+func TestFaultPoolOnGetFail(t *testing.T) {
+	myErr := errors.New("sorry")
+	faults := NewFaultPool(NewServer().NewPool())
+	faults.OnGet().Times(1).Fail(myErr)
+
+	c := faults.Get()
+	_, err := c.Do("PING")
+	assert.Equal(t, myErr, err)
+
+	c = faults.Get()
+	defer c.Close()
+	_, err = c.Do("PING")
+	assert.NoError(t, err)
+}
+
+func TestFaultPoolCommandFailWithAfterAndTimes(t *testing.T) {
+	faults := NewFaultPool(NewServer().NewPool())
+	faults.OnCommand("ping").After(1).Times(1).Fail(nil)
+	c := faults.Get()
+	defer c.Close()
+
+	_, err := c.Do("PING")
+	assert.NoError(t, err)
+	_, err = c.Do("PING")
+	assert.Equal(t, ErrInjectedFault, err)
+	_, err = c.Do("PING")
+	assert.NoError(t, err)
+}
+
+func TestFaultPoolPartialPipelineFailure(t *testing.T) {
+	faults := NewFaultPool(NewServer().NewPool())
+	faults.OnCommand("HSET").After(1).Times(1).Fail(r.Error("LOADING Redis is loading the dataset in memory"))
+	c := faults.Get()
+	defer c.Close()
+
+	_ = c.Send("HSET", "h", "a", "1")
+	_ = c.Send("HSET", "h", "b", "2")
+	_ = c.Send("HSET", "h", "c", "3")
+	replies, err := r.Values(c.Do(""))
+	require.NoError(t, err)
+	require.Len(t, replies, 3)
+	assert.Equal(t, int64(1), replies[0])
+	assert.Equal(t, r.Error("LOADING Redis is loading the dataset in memory"), replies[1])
+	assert.Equal(t, int64(1), replies[2])
+
+	values, err := r.StringMap(c.Do("HGETALL", "h"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, values)
+}
+
+func TestFaultPoolAbortExec(t *testing.T) {
+	faults := NewFaultPool(NewServer().NewPool())
+	faults.OnCommand("EXEC").Times(1).AbortExec()
+	c := faults.Get()
+	defer c.Close()
+
+	_ = c.Send("MULTI")
+	_ = c.Send("SET", "k", "v")
+	result, err := c.Do("EXEC")
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	exists, err := r.Bool(c.Do("EXISTS", "k"))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestFaultPoolDropConnectionMidTransaction(t *testing.T) {
+	server := NewServer()
+	faults := NewFaultPool(server.NewPool())
+	faults.OnCommand("EXEC").Times(1).DropConnection()
+	c := faults.Get()
+	defer c.Close()
+
+	_ = c.Send("MULTI")
+	_ = c.Send("SET", "k", "v")
+	_, err := c.Do("EXEC")
+	assert.Equal(t, ErrInjectedFault, err)
+	assert.Equal(t, ErrInjectedFault, c.Err())
+	_, err = c.Do("PING")
+	assert.Equal(t, ErrInjectedFault, err)
+
+	c2 := server.NewPool().Get()
+	defer c2.Close()
+	exists, err := r.Bool(c2.Do("EXISTS", "k"))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestFaultPoolDelay(t *testing.T) {
+	faults := NewFaultPool(NewServer().NewPool())
+	faults.OnCommand("*").Delay(20 * time.Millisecond)
+	c := faults.Get()
+	defer c.Close()
+
+	start := time.Now()
+	_, err := c.Do("PING")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestFaultPoolClear(t *testing.T) {
+	faults := NewFaultPool(NewServer().NewPool())
+	faults.OnCommand("*").Fail(nil)
+	faults.Clear()
+	c := faults.Get()
+	defer c.Close()
+
+	_, err := c.Do("PING")
+	assert.NoError(t, err)
+}
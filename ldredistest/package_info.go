@@ -0,0 +1,24 @@
+// Package ldredistest provides test helpers for code that uses the Redis data store from
+// github.com/launchdarkly/go-server-sdk-redis-redigo/v3.
+//
+// The main component is [Server], an in-memory stand-in for a Redis server that implements the
+// subset of Redis commands used by the data store and Big Segment store. Each call to
+// [Server.NewPool] returns a [Pool] that can be passed to StoreBuilder.PoolInterface, so that
+// unit tests do not need a real Redis instance:
+//
+//	server := ldredistest.NewServer()
+//	config.DataStore = ldcomponents.PersistentDataStore(
+//		ldredis.DataStore().PoolInterface(server.NewPool()),
+//	)
+//
+// Stores built with pools from the same Server see the same data, just as stores connected to
+// the same Redis database would.
+//
+// [FaultPool] wraps any pool, including a real Redigo pool, and injects latency, connection errors,
+// failed commands, aborted transactions, and dropped connections according to rules that can be
+// scripted per command. This makes it possible to test how an application behaves when Redis is
+// unavailable or misbehaving.
+//
+// This package is intended for tests only. It does not attempt to reproduce Redis behavior
+// beyond what the LaunchDarkly stores rely on.
+package ldredistest
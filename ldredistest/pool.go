@@ -0,0 +1,253 @@
+package ldredistest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	r "github.com/gomodule/redigo/redis"
+)
+
+var (
+	errPoolClosed = errors.New("ldredistest: connection pool closed")
+	errConnClosed = errors.New("ldredistest: connection closed")
+	errNoReplies  = errors.New("ldredistest: no pending replies")
+)
+
+// Pool is a connection pool for a [Server]. It implements the Pool interface of the ldredis
+// package.
+//
+// Use [Server.NewPool] to create a Pool.
+type Pool struct {
+	server *Server
+	lock   sync.Mutex
+	closed bool
+}
+
+// Get obtains a connection to the Server. If the pool has been closed, every operation on the
+// returned connection fails.
+func (p *Pool) Get() r.Conn {
+	p.lock.Lock()
+	closed := p.closed
+	p.lock.Unlock()
+	if closed {
+		return errorConn{errPoolClosed}
+	}
+	return &conn{server: p.server}
+}
+
+// Close marks the pool as closed. It does not affect the data held by the Server.
+func (p *Pool) Close() error {
+	p.lock.Lock()
+	p.closed = true
+	p.lock.Unlock()
+	return nil
+}
+
+type command struct {
+	name string
+	args []string
+}
+
+// conn is an r.Conn that executes commands against a Server. Like a real Redis connection, it
+// buffers commands passed to Send until Flush, Receive or Do is called, and it keeps track of its
+// own WATCH and MULTI state.
+type conn struct {
+	server    *Server
+	pending   []command
+	replies   []interface{}
+	watched   map[string]uint64
+	queued    []command
+	inMulti   bool
+	multiFail bool
+	closed    bool
+}
+
+func (c *conn) Close() error {
+	if c.closed {
+		return errConnClosed
+	}
+	c.closed = true
+	c.pending, c.replies, c.queued, c.watched = nil, nil, nil, nil
+	return nil
+}
+
+func (c *conn) Err() error {
+	if c.closed {
+		return errConnClosed
+	}
+	return nil
+}
+
+func (c *conn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if c.closed {
+		return nil, errConnClosed
+	}
+	if commandName == "" {
+		_ = c.Flush()
+		replies := c.replies
+		c.replies = nil
+		return replies, nil
+	}
+	_ = c.Send(commandName, args...)
+	_ = c.Flush()
+	replies := c.replies
+	c.replies = nil
+	var err error
+	for _, reply := range replies {
+		if e, ok := reply.(r.Error); ok && err == nil {
+			err = e
+		}
+	}
+	return replies[len(replies)-1], err
+}
+
+func (c *conn) Send(commandName string, args ...interface{}) error {
+	if c.closed {
+		return errConnClosed
+	}
+	cmd := command{name: strings.ToUpper(commandName), args: make([]string, len(args))}
+	for i, arg := range args {
+		cmd.args[i] = argString(arg)
+	}
+	c.pending = append(c.pending, cmd)
+	return nil
+}
+
+func (c *conn) Flush() error {
+	if c.closed {
+		return errConnClosed
+	}
+	if len(c.pending) == 0 {
+		return nil
+	}
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	for _, cmd := range c.pending {
+		c.replies = append(c.replies, c.execute(cmd))
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *conn) Receive() (interface{}, error) {
+	if c.closed {
+		return nil, errConnClosed
+	}
+	_ = c.Flush()
+	if len(c.replies) == 0 {
+		return nil, errNoReplies
+	}
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	if e, ok := reply.(r.Error); ok {
+		return reply, e
+	}
+	return reply, nil
+}
+
+// execute runs a command in the context of this connection's transaction state. The caller must
+// hold the server lock.
+func (c *conn) execute(cmd command) interface{} {
+	switch cmd.name {
+	case "WATCH":
+		if c.inMulti {
+			return r.Error("ERR WATCH inside MULTI is not allowed")
+		}
+		if len(cmd.args) == 0 {
+			return wrongArgs("watch")
+		}
+		if c.watched == nil {
+			c.watched = make(map[string]uint64)
+		}
+		for _, key := range cmd.args {
+			c.watched[key] = c.server.versions[key]
+		}
+		return "OK"
+	case "UNWATCH":
+		c.watched = nil
+		return "OK"
+	case "MULTI":
+		if c.inMulti {
+			return r.Error("ERR MULTI calls can not be nested")
+		}
+		c.inMulti, c.multiFail, c.queued = true, false, nil
+		return "OK"
+	case "DISCARD":
+		if !c.inMulti {
+			return r.Error("ERR DISCARD without MULTI")
+		}
+		c.inMulti, c.queued, c.watched = false, nil, nil
+		return "OK"
+	case "EXEC":
+		if !c.inMulti {
+			return r.Error("ERR EXEC without MULTI")
+		}
+		queued, failed, watched := c.queued, c.multiFail, c.watched
+		c.inMulti, c.queued, c.watched = false, nil, nil
+		if failed {
+			return r.Error("EXECABORT Transaction discarded because of previous errors.")
+		}
+		for key, version := range watched {
+			if c.server.versions[key] != version {
+				return nil
+			}
+		}
+		results := make([]interface{}, len(queued))
+		for i, q := range queued {
+			results[i] = c.server.execute(q.name, q.args)
+		}
+		return results
+	}
+	if c.inMulti {
+		if _, ok := commands[cmd.name]; !ok {
+			c.multiFail = true
+			return c.server.execute(cmd.name, cmd.args)
+		}
+		c.queued = append(c.queued, cmd)
+		return "QUEUED"
+	}
+	return c.server.execute(cmd.name, cmd.args)
+}
+
+// argString converts a command argument to a string in the same way that Redigo does when it
+// writes the argument to the network.
+func argString(arg interface{}) string {
+	switch a := arg.(type) {
+	case string:
+		return a
+	case []byte:
+		return string(a)
+	case int:
+		return strconv.Itoa(a)
+	case int64:
+		return strconv.FormatInt(a, 10)
+	case float64:
+		return strconv.FormatFloat(a, 'g', -1, 64)
+	case bool:
+		if a {
+			return "1"
+		}
+		return "0"
+	case nil:
+		return ""
+	case r.Argument:
+		return argString(a.RedisArg())
+	default:
+		return fmt.Sprint(a)
+	}
+}
+
+// errorConn is a connection whose operations all fail with the same error.
+type errorConn struct {
+	err error
+}
+
+func (ec errorConn) Close() error                                   { return nil }
+func (ec errorConn) Err() error                                     { return ec.err }
+func (ec errorConn) Do(string, ...interface{}) (interface{}, error) { return nil, ec.err }
+func (ec errorConn) Send(string, ...interface{}) error              { return ec.err }
+func (ec errorConn) Flush() error                                   { return ec.err }
+func (ec errorConn) Receive() (interface{}, error)                  { return nil, ec.err }
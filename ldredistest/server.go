@@ -0,0 +1,563 @@
+package ldredistest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+)
+
+// Server is an in-memory stand-in for a Redis server.
+//
+// It supports the following commands: PING, GET, SET, INCR, DEL, EXISTS, RENAME, PEXPIRE, PERSIST,
+// PTTL, HGET, HGETALL, HSET, HDEL, HLEN, HSCAN, SADD, SMEMBERS, SCAN, and the transaction commands
+// WATCH, UNWATCH, MULTI, EXEC and DISCARD. Any other command returns an error reply, as Redis would
+// for an unknown command.
+//
+// SET supports the EX, PX, NX and XX options, and keys that have expired are removed before each
+// command.
+//
+// A Server is safe for concurrent use. All connections obtained from pools created by the same
+// Server share its data.
+type Server struct {
+	lock        sync.Mutex
+	entries     map[string]*entry
+	versions    map[string]uint64
+	lastVersion uint64
+}
+
+// entry is a single Redis value. Exactly one of str, hash and set is non-nil.
+type entry struct {
+	str       []byte
+	hash      map[string][]byte
+	set       map[string]struct{}
+	expiresAt time.Time // zero if the key does not expire
+}
+
+type commandFunc func(s *Server, args []string) interface{}
+
+var commands = map[string]commandFunc{
+	"PING":     (*Server).ping,
+	"GET":      (*Server).get,
+	"SET":      (*Server).set,
+	"INCR":     (*Server).incr,
+	"DEL":      (*Server).del,
+	"EXISTS":   (*Server).exists,
+	"RENAME":   (*Server).rename,
+	"PEXPIRE":  (*Server).pexpire,
+	"PERSIST":  (*Server).persist,
+	"PTTL":     (*Server).pttl,
+	"HGET":     (*Server).hget,
+	"HGETALL":  (*Server).hgetall,
+	"HSET":     (*Server).hset,
+	"HDEL":     (*Server).hdel,
+	"HLEN":     (*Server).hlen,
+	"HSCAN":    (*Server).hscan,
+	"SADD":     (*Server).sadd,
+	"SMEMBERS": (*Server).smembers,
+	"SCAN":     (*Server).scan,
+}
+
+var errWrongType = r.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// NewServer creates an empty Server.
+func NewServer() *Server {
+	return &Server{
+		entries:  make(map[string]*entry),
+		versions: make(map[string]uint64),
+	}
+}
+
+// NewPool returns a new connection pool for this Server. The pool implements the Pool interface
+// of the ldredis package, so it can be passed to StoreBuilder.PoolInterface.
+//
+// Closing the pool does not affect the Server's data, so a test can build several stores in
+// sequence, each with its own pool, and they will all see the same data.
+func (s *Server) NewPool() *Pool {
+	return &Pool{server: s}
+}
+
+// execute runs a single command. The caller must hold the lock.
+func (s *Server) execute(name string, args []string) interface{} {
+	fn, ok := commands[name]
+	if !ok {
+		return r.Error(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+	}
+	s.removeExpired()
+	return fn(s, args)
+}
+
+// removeExpired deletes all keys whose expiry time has passed. The caller must hold the lock.
+func (s *Server) removeExpired() {
+	now := time.Now()
+	for key, e := range s.entries {
+		if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+			s.touch(key)
+		}
+	}
+}
+
+// touch records that a key has been modified, so that any connection that is watching it will
+// have its next EXEC aborted. The caller must hold the lock.
+func (s *Server) touch(key string) {
+	s.lastVersion++
+	s.versions[key] = s.lastVersion
+}
+
+func (s *Server) ping(args []string) interface{} {
+	switch len(args) {
+	case 0:
+		return "PONG"
+	case 1:
+		return []byte(args[0])
+	default:
+		return wrongArgs("ping")
+	}
+}
+
+func (s *Server) get(args []string) interface{} {
+	if len(args) != 1 {
+		return wrongArgs("get")
+	}
+	e := s.entries[args[0]]
+	if e == nil {
+		return nil
+	}
+	if e.str == nil {
+		return errWrongType
+	}
+	return copyBytes(e.str)
+}
+
+func (s *Server) set(args []string) interface{} {
+	if len(args) < 2 {
+		return wrongArgs("set")
+	}
+	e := &entry{str: []byte(args[1])}
+	_, exists := s.entries[args[0]]
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "NX", "XX":
+			if exists == (option == "NX") {
+				return nil
+			}
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				return r.Error("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return r.Error("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Millisecond
+			if option == "EX" {
+				unit = time.Second
+			}
+			e.expiresAt = time.Now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			return r.Error("ERR syntax error")
+		}
+	}
+	s.entries[args[0]] = e
+	s.touch(args[0])
+	return "OK"
+}
+
+func (s *Server) incr(args []string) interface{} {
+	if len(args) != 1 {
+		return wrongArgs("incr")
+	}
+	var n int64
+	if e := s.entries[args[0]]; e != nil {
+		if e.str == nil {
+			return errWrongType
+		}
+		var err error
+		if n, err = strconv.ParseInt(string(e.str), 10, 64); err != nil {
+			return r.Error("ERR value is not an integer or out of range")
+		}
+	}
+	n++
+	s.entries[args[0]] = &entry{str: []byte(strconv.FormatInt(n, 10))}
+	s.touch(args[0])
+	return n
+}
+
+func (s *Server) del(args []string) interface{} {
+	if len(args) == 0 {
+		return wrongArgs("del")
+	}
+	var count int64
+	for _, key := range args {
+		if _, ok := s.entries[key]; ok {
+			delete(s.entries, key)
+			s.touch(key)
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) rename(args []string) interface{} {
+	if len(args) != 2 {
+		return wrongArgs("rename")
+	}
+	e, ok := s.entries[args[0]]
+	if !ok {
+		return r.Error("ERR no such key")
+	}
+	delete(s.entries, args[0])
+	s.entries[args[1]] = e
+	s.touch(args[0])
+	s.touch(args[1])
+	return "OK"
+}
+
+func (s *Server) pexpire(args []string) interface{} {
+	if len(args) != 2 {
+		return wrongArgs("pexpire")
+	}
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return r.Error("ERR value is not an integer or out of range")
+	}
+	e, ok := s.entries[args[0]]
+	if !ok {
+		return int64(0)
+	}
+	e.expiresAt = time.Now().Add(time.Duration(n) * time.Millisecond)
+	s.touch(args[0])
+	return int64(1)
+}
+
+func (s *Server) persist(args []string) interface{} {
+	if len(args) != 1 {
+		return wrongArgs("persist")
+	}
+	e, ok := s.entries[args[0]]
+	if !ok || e.expiresAt.IsZero() {
+		return int64(0)
+	}
+	e.expiresAt = time.Time{}
+	s.touch(args[0])
+	return int64(1)
+}
+
+func (s *Server) pttl(args []string) interface{} {
+	if len(args) != 1 {
+		return wrongArgs("pttl")
+	}
+	e, ok := s.entries[args[0]]
+	switch {
+	case !ok:
+		return int64(-2)
+	case e.expiresAt.IsZero():
+		return int64(-1)
+	default:
+		return time.Until(e.expiresAt).Milliseconds()
+	}
+}
+
+func (s *Server) exists(args []string) interface{} {
+	if len(args) == 0 {
+		return wrongArgs("exists")
+	}
+	var count int64
+	for _, key := range args {
+		if _, ok := s.entries[key]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) hget(args []string) interface{} {
+	if len(args) != 2 {
+		return wrongArgs("hget")
+	}
+	e := s.entries[args[0]]
+	if e == nil {
+		return nil
+	}
+	if e.hash == nil {
+		return errWrongType
+	}
+	if value, ok := e.hash[args[1]]; ok {
+		return copyBytes(value)
+	}
+	return nil
+}
+
+func (s *Server) hgetall(args []string) interface{} {
+	if len(args) != 1 {
+		return wrongArgs("hgetall")
+	}
+	e := s.entries[args[0]]
+	if e == nil {
+		return []interface{}{}
+	}
+	if e.hash == nil {
+		return errWrongType
+	}
+	fields := make([]string, 0, len(e.hash))
+	for field := range e.hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	ret := make([]interface{}, 0, len(fields)*2)
+	for _, field := range fields {
+		ret = append(ret, []byte(field), copyBytes(e.hash[field]))
+	}
+	return ret
+}
+
+func (s *Server) hset(args []string) interface{} {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return wrongArgs("hset")
+	}
+	key := args[0]
+	e := s.entries[key]
+	if e == nil {
+		e = &entry{hash: make(map[string][]byte)}
+		s.entries[key] = e
+	} else if e.hash == nil {
+		return errWrongType
+	}
+	var added int64
+	for i := 1; i < len(args); i += 2 {
+		if _, ok := e.hash[args[i]]; !ok {
+			added++
+		}
+		e.hash[args[i]] = []byte(args[i+1])
+	}
+	s.touch(key)
+	return added
+}
+
+func (s *Server) hdel(args []string) interface{} {
+	if len(args) < 2 {
+		return wrongArgs("hdel")
+	}
+	key := args[0]
+	e := s.entries[key]
+	if e == nil {
+		return int64(0)
+	}
+	if e.hash == nil {
+		return errWrongType
+	}
+	var removed int64
+	for _, field := range args[1:] {
+		if _, ok := e.hash[field]; ok {
+			delete(e.hash, field)
+			removed++
+		}
+	}
+	if removed > 0 {
+		if len(e.hash) == 0 {
+			delete(s.entries, key) // as in Redis, a hash with no fields does not exist
+		}
+		s.touch(key)
+	}
+	return removed
+}
+
+func (s *Server) hlen(args []string) interface{} {
+	if len(args) != 1 {
+		return wrongArgs("hlen")
+	}
+	e := s.entries[args[0]]
+	if e == nil {
+		return int64(0)
+	}
+	if e.hash == nil {
+		return errWrongType
+	}
+	return int64(len(e.hash))
+}
+
+// hscan implements HSCAN with optional MATCH and COUNT arguments. The fields are returned in sorted
+// order, and the cursor is the number of fields that have been scanned so far. Unlike Redis, the
+// number of fields scanned in each call is always exactly COUNT (default 10), which makes it easy
+// to test how callers handle multiple batches.
+func (s *Server) hscan(args []string) interface{} {
+	if len(args) < 2 {
+		return wrongArgs("hscan")
+	}
+	cursor, err := strconv.Atoi(args[1])
+	if err != nil || cursor < 0 {
+		return r.Error("ERR invalid cursor")
+	}
+	pattern, count := "*", 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return r.Error("ERR syntax error")
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return r.Error("ERR syntax error")
+			}
+		default:
+			return r.Error("ERR syntax error")
+		}
+	}
+	e := s.entries[args[0]]
+	if e == nil {
+		return []interface{}{[]byte("0"), []interface{}{}}
+	}
+	if e.hash == nil {
+		return errWrongType
+	}
+	fields := make([]string, 0, len(e.hash))
+	for field := range e.hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	end := cursor + count
+	if end >= len(fields) {
+		end = len(fields)
+	}
+	ret := make([]interface{}, 0)
+	for i := cursor; i < end; i++ {
+		if matchPattern(pattern, fields[i]) {
+			ret = append(ret, []byte(fields[i]), copyBytes(e.hash[fields[i]]))
+		}
+	}
+	next := end
+	if next >= len(fields) {
+		next = 0
+	}
+	return []interface{}{[]byte(strconv.Itoa(next)), ret}
+}
+
+func (s *Server) sadd(args []string) interface{} {
+	if len(args) < 2 {
+		return wrongArgs("sadd")
+	}
+	key := args[0]
+	e := s.entries[key]
+	if e == nil {
+		e = &entry{set: make(map[string]struct{})}
+		s.entries[key] = e
+	} else if e.set == nil {
+		return errWrongType
+	}
+	var added int64
+	for _, member := range args[1:] {
+		if _, ok := e.set[member]; !ok {
+			e.set[member] = struct{}{}
+			added++
+		}
+	}
+	s.touch(key)
+	return added
+}
+
+func (s *Server) smembers(args []string) interface{} {
+	if len(args) != 1 {
+		return wrongArgs("smembers")
+	}
+	e := s.entries[args[0]]
+	if e == nil {
+		return []interface{}{}
+	}
+	if e.set == nil {
+		return errWrongType
+	}
+	members := make([]string, 0, len(e.set))
+	for member := range e.set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return stringsReply(members)
+}
+
+// scan implements SCAN with an optional MATCH pattern. All matching keys are returned in a single
+// batch, so the returned cursor is always 0.
+func (s *Server) scan(args []string) interface{} {
+	if len(args) == 0 {
+		return wrongArgs("scan")
+	}
+	if _, err := strconv.ParseUint(args[0], 10, 64); err != nil {
+		return r.Error("ERR invalid cursor")
+	}
+	pattern := "*"
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return r.Error("ERR syntax error")
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+		default:
+			return r.Error("ERR syntax error")
+		}
+	}
+	keys := make([]string, 0)
+	for key := range s.entries {
+		if matchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return []interface{}{[]byte("0"), stringsReply(keys)}
+}
+
+// matchPattern implements the subset of Redis glob-style patterns that consists of "*", "?" and
+// backslash escapes.
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+func wrongArgs(name string) r.Error {
+	return r.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+}
+
+func stringsReply(values []string) []interface{} {
+	ret := make([]interface{}, len(values))
+	for i, v := range values {
+		ret[i] = []byte(v)
+	}
+	return ret
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
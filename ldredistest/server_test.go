@@ -0,0 +1,273 @@
+package ldredistest
+
+import (
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchedKeyModificationAbortsExec(t *testing.T) {
+	pool := NewServer().NewPool()
+	c1, c2 := pool.Get(), pool.Get()
+	defer c1.Close()
+	defer c2.Close()
+
+	_, err := c1.Do("WATCH", "k")
+	require.NoError(t, err)
+	_, err = c2.Do("SET", "k", "other")
+	require.NoError(t, err)
+
+	_ = c1.Send("MULTI")
+	_ = c1.Send("SET", "k", "mine")
+	result, err := c1.Do("EXEC")
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	value, err := r.String(c1.Do("GET", "k"))
+	require.NoError(t, err)
+	assert.Equal(t, "other", value)
+}
+
+func TestUnmodifiedWatchedKeyAllowsExec(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("WATCH", "k")
+	require.NoError(t, err)
+	_ = c.Send("MULTI")
+	_ = c.Send("HSET", "k", "f1", "v1", "f2", "v2")
+	_ = c.Send("HGET", "k", "f2")
+	result, err := r.Values(c.Do("EXEC"))
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(2), []byte("v2")}, result)
+}
+
+func TestHScanReturnsFieldsInBatches(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+	_, err := c.Do("HSET", "h", "a", "1", "b", "2", "c", "3")
+	require.NoError(t, err)
+
+	n, err := r.Int(c.Do("HLEN", "h"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	reply, err := r.Values(c.Do("HSCAN", "h", "0", "COUNT", "2"))
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(reply[0].([]byte)))
+	fields, _ := r.StringMap(reply[1], nil)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, fields)
+
+	reply, err = r.Values(c.Do("HSCAN", "h", "2", "COUNT", "2"))
+	require.NoError(t, err)
+	assert.Equal(t, "0", string(reply[0].([]byte)))
+	fields, _ = r.StringMap(reply[1], nil)
+	assert.Equal(t, map[string]string{"c": "3"}, fields)
+
+	reply, err = r.Values(c.Do("HSCAN", "missing", "0"))
+	require.NoError(t, err)
+	assert.Equal(t, "0", string(reply[0].([]byte)))
+	assert.Len(t, reply[1], 0)
+}
+
+func TestHDelRemovesFields(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+	_, err := c.Do("HSET", "h", "a", "1", "b", "2")
+	require.NoError(t, err)
+
+	n, err := r.Int(c.Do("HDEL", "h", "a", "missing"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = r.Int(c.Do("HDEL", "h", "b"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	exists, err := r.Int(c.Do("EXISTS", "h"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, exists)
+}
+
+func TestRenameReplacesDestination(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+	_, err := c.Do("HSET", "new", "a", "1")
+	require.NoError(t, err)
+	_, err = c.Do("HSET", "old", "b", "2")
+	require.NoError(t, err)
+
+	_, err = c.Do("RENAME", "new", "old")
+	require.NoError(t, err)
+	fields, err := r.StringMap(c.Do("HGETALL", "old"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, fields)
+	exists, err := r.Int(c.Do("EXISTS", "new"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, exists)
+
+	_, err = c.Do("RENAME", "new", "old")
+	assert.EqualError(t, err, "ERR no such key")
+}
+
+func TestSetWithExpiry(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("SET", "k", "v", "PX", 20)
+	require.NoError(t, err)
+	value, err := r.String(c.Do("GET", "k"))
+	require.NoError(t, err)
+	assert.Equal(t, "v", value)
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = r.String(c.Do("GET", "k"))
+	assert.Equal(t, r.ErrNil, err)
+
+	_, err = c.Do("SET", "k", "v", "EX", 0)
+	assert.Error(t, err)
+	_, err = c.Do("SET", "k", "v", "KEEPTTL")
+	assert.Error(t, err)
+}
+
+func TestExpireAndPersist(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+
+	ttl, err := r.Int64(c.Do("PTTL", "k"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-2), ttl)
+	set, err := r.Int(c.Do("PEXPIRE", "k", 1000))
+	require.NoError(t, err)
+	assert.Equal(t, 0, set, "a key that does not exist has no expiry time")
+
+	_, err = c.Do("HSET", "k", "a", "1")
+	require.NoError(t, err)
+	ttl, err = r.Int64(c.Do("PTTL", "k"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl)
+
+	set, err = r.Int(c.Do("PEXPIRE", "k", 1000))
+	require.NoError(t, err)
+	assert.Equal(t, 1, set)
+	ttl, err = r.Int64(c.Do("PTTL", "k"))
+	require.NoError(t, err)
+	assert.InDelta(t, 1000, ttl, 100)
+
+	_, err = c.Do("RENAME", "k", "k2")
+	require.NoError(t, err)
+	ttl, err = r.Int64(c.Do("PTTL", "k2"))
+	require.NoError(t, err)
+	assert.Greater(t, ttl, int64(0), "RENAME keeps the expiry time")
+
+	persisted, err := r.Int(c.Do("PERSIST", "k2"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, persisted)
+	ttl, err = r.Int64(c.Do("PTTL", "k2"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl)
+
+	_, err = c.Do("PEXPIRE", "k2", 10)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	exists, err := r.Int(c.Do("EXISTS", "k2"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, exists)
+}
+
+func TestSetWithCondition(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+
+	reply, err := c.Do("SET", "k", "v1", "XX")
+	require.NoError(t, err)
+	assert.Nil(t, reply)
+	reply, err = c.Do("SET", "k", "v1", "NX", "PX", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	reply, err = c.Do("SET", "k", "v2", "NX")
+	require.NoError(t, err)
+	assert.Nil(t, reply)
+	reply, err = c.Do("SET", "k", "v3", "XX")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+
+	value, err := r.String(c.Do("GET", "k"))
+	require.NoError(t, err)
+	assert.Equal(t, "v3", value)
+}
+
+func TestIncr(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+
+	n, err := r.Int(c.Do("INCR", "n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = r.Int(c.Do("INCR", "n"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, err = c.Do("SET", "s", "x")
+	require.NoError(t, err)
+	_, err = c.Do("INCR", "s")
+	assert.Error(t, err)
+}
+
+func TestWrongTypeReturnsError(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("SET", "k", "v")
+	require.NoError(t, err)
+	_, err = c.Do("HGET", "k", "f")
+	assert.Error(t, err)
+}
+
+func TestUnknownCommandReturnsError(t *testing.T) {
+	pool := NewServer().NewPool()
+	c := pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("FLUSHALL")
+	assert.EqualError(t, err, "ERR unknown command 'flushall'")
+}
+
+func TestClosedPoolReturnsFailingConnections(t *testing.T) {
+	pool := NewServer().NewPool()
+	require.NoError(t, pool.Close())
+
+	c := pool.Get()
+	assert.Equal(t, errPoolClosed, c.Err())
+	_, err := c.Do("PING")
+	assert.Equal(t, errPoolClosed, err)
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, p := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a:*", "a:b", true},
+		{"a:*", "b:a", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"*:x", "a:b:x", true},
+	} {
+		assert.Equal(t, p.match, matchPattern(p.pattern, p.s), "%q %q", p.pattern, p.s)
+	}
+}
@@ -31,7 +31,8 @@
 // can also be specified as part of the URL: Redigo supports the redis:// syntax
 // (https://www.iana.org/assignments/uri-schemes/prov/redis), which can include a password
 // and a database number, as well as rediss:// (https://www.iana.org/assignments/uri-schemes/prov/rediss),
-// which enables TLS.
+// which enables TLS. To connect through a Unix domain socket, use a unix:// URL or
+// [StoreBuilder.SocketPath].
 //
 // If you are also using Redis for other purposes, the data store can coexist with
 // other data as long as you are not using the same keys. By default, the keys used by the
@@ -0,0 +1,208 @@
+package ldredis
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// The benchmarks in this file run against every configuration in benchmarkConfigs. The sub-benchmark
+// names have the form "Benchmark<Operation>/config=<name>/<parameter>=<value>", so results from
+// several runs can be compared with benchstat, for instance:
+//
+//     make benchmarks
+//     benchstat -col /config build/benchmarks.txt
+//
+// Configurations that use a real Redis instance are skipped if there is no Redis server at redisURL.
+
+const benchmarkPrefix = "ldbenchmark"
+
+// benchmarkConfig describes a store configuration to be benchmarked. The newPool function is called
+// once for each benchmark; all stores and connections used by that benchmark share the pool.
+type benchmarkConfig struct {
+	name      string
+	realRedis bool
+	newPool   func() Pool
+}
+
+var benchmarkConfigs = []benchmarkConfig{
+	{
+		name:      "redis",
+		realRedis: true,
+		newPool:   func() Pool { return newPool(builderOptions{url: redisURL}, ldlog.NewDisabledLoggers()) },
+	},
+	{
+		name:    "fake",
+		newPool: func() Pool { return ldredistest.NewServer().NewPool() },
+	},
+}
+
+var benchmarkDataSizes = []int{100, 1000, 10000}
+
+func BenchmarkInit(b *testing.B) {
+	forEachBenchmarkConfig(b, func(b *testing.B, config benchmarkConfig) {
+		for _, size := range benchmarkDataSizes {
+			b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+				store := makeBenchmarkDataStore(b, config)
+				data := makeBenchmarkFlagData(size)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := store.Init(data); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	forEachBenchmarkConfig(b, func(b *testing.B, config benchmarkConfig) {
+		store := makeBenchmarkDataStore(b, config)
+		if err := store.Init(makeBenchmarkFlagData(100)); err != nil {
+			b.Fatal(err)
+		}
+		for _, key := range []string{"flag0", "missing"} {
+			b.Run(fmt.Sprintf("key=%s", key), func(b *testing.B) {
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := store.Get(ldstoreimpl.Features(), key); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkGetAll(b *testing.B) {
+	forEachBenchmarkConfig(b, func(b *testing.B, config benchmarkConfig) {
+		for _, size := range benchmarkDataSizes {
+			b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+				store := makeBenchmarkDataStore(b, config)
+				if err := store.Init(makeBenchmarkFlagData(size)); err != nil {
+					b.Fatal(err)
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := store.GetAll(ldstoreimpl.Features()); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkUpsertConcurrent(b *testing.B) {
+	forEachBenchmarkConfig(b, func(b *testing.B, config benchmarkConfig) {
+		// With a single key, every concurrent Upsert conflicts with the others and some of them have
+		// to retry their transactions; with more keys, conflicts are rarer.
+		for _, keyCount := range []int{1, 100} {
+			b.Run(fmt.Sprintf("keys=%d", keyCount), func(b *testing.B) {
+				store := makeBenchmarkDataStore(b, config)
+				if err := store.Init(makeBenchmarkFlagData(keyCount)); err != nil {
+					b.Fatal(err)
+				}
+				var counter int64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						// Each Upsert has a newer version than any before it, so that it is never rejected
+						// as an old version. Concurrent Upserts may still finish out of order, in which
+						// case the later one is rejected, just as it would be in real use.
+						n := int(atomic.AddInt64(&counter, 1))
+						item := makeTestFlag(fmt.Sprintf("flag%d", n%keyCount), n+1)
+						if _, err := store.Upsert(ldstoreimpl.Features(), item.Key, item.Item); err != nil {
+							b.Error(err) // can't use Fatal outside of the benchmark goroutine
+							return
+						}
+					}
+				})
+			})
+		}
+	})
+}
+
+func BenchmarkGetMembership(b *testing.B) {
+	forEachBenchmarkConfig(b, func(b *testing.B, config benchmarkConfig) {
+		pool := config.newPool()
+		conn := pool.Get()
+		for i := 0; i < 10; i++ {
+			_ = conn.Send("SADD", bigSegmentsIncludeKey(benchmarkPrefix, "context"), fmt.Sprintf("segment%d.g1", i))
+			_ = conn.Send("SADD", bigSegmentsExcludeKey(benchmarkPrefix, "context"), fmt.Sprintf("other%d.g1", i))
+		}
+		if _, err := conn.Do(""); err != nil {
+			b.Fatal(err)
+		}
+		_ = conn.Close()
+
+		store, err := BigSegmentStore().Prefix(benchmarkPrefix).PoolInterface(pool).Build(makeBenchmarkContext())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer store.Close() //nolint:errcheck
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetMembership("context"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func forEachBenchmarkConfig(b *testing.B, action func(*testing.B, benchmarkConfig)) {
+	for _, config := range benchmarkConfigs {
+		config := config
+		b.Run("config="+config.name, func(b *testing.B) {
+			if config.realRedis {
+				requireRealRedisForBenchmark(b)
+			}
+			action(b, config)
+		})
+	}
+}
+
+func requireRealRedisForBenchmark(b *testing.B) {
+	c, err := r.DialURL(redisURL, r.DialConnectTimeout(100*time.Millisecond))
+	if err != nil {
+		b.Skipf("Redis is not available at %s: %s", redisURL, err)
+	}
+	defer c.Close() //nolint:errcheck
+	if err := clearTestDataWithConn(c, benchmarkPrefix); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func makeBenchmarkDataStore(b *testing.B, config benchmarkConfig) subsystems.PersistentDataStore {
+	store, err := DataStore().Prefix(benchmarkPrefix).PoolInterface(config.newPool()).Build(makeBenchmarkContext())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func makeBenchmarkFlagData(size int) []ldstoretypes.SerializedCollection {
+	flags := make([]ldstoretypes.KeyedSerializedItemDescriptor, size)
+	for i := range flags {
+		flags[i] = makeTestFlag(fmt.Sprintf("flag%d", i), 1)
+	}
+	return makeTestFlagData(flags...)
+}
+
+func makeBenchmarkContext() subsystems.ClientContext {
+	var context subsystems.BasicClientContext
+	context.Logging.Loggers = ldlog.NewDisabledLoggers()
+	return context
+}
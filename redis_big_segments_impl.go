@@ -14,9 +14,12 @@ import (
 
 // Internal implementation of the BigSegmentStore interface for Redis.
 type redisBigSegmentStoreImpl struct {
-	prefix  string
-	pool    Pool
-	loggers ldlog.Loggers
+	prefix    string
+	pool      Pool
+	idleConns *idleConnMaintainer
+	breaker   *circuitBreaker
+	retrier   *readRetrier
+	loggers   ldlog.Loggers
 }
 
 func newRedisBigSegmentStoreImpl(
@@ -31,13 +34,23 @@ func newRedisBigSegmentStoreImpl(
 	impl.loggers.SetPrefix("RedisBigSegmentStore:")
 
 	if impl.pool == nil {
-		logRedisURL(loggers, builder.url)
-		impl.pool = newPool(builder.url, builder.dialOptions)
+		logRedisURL(loggers, builder.effectiveURL())
+		pool := newPool(builder, impl.loggers)
+		impl.pool = pool
+		impl.idleConns = startIdleConnMaintainer(pool, builder.poolLimits.minIdle, minIdleCheckInterval, impl.loggers)
 	}
+	impl.breaker = newCircuitBreaker(builder.circuitBreaker, impl.checkAvailable, impl.loggers)
+	impl.retrier = newReadRetrier(builder.readRetry, impl.loggers)
 	return impl
 }
 
 func (store *redisBigSegmentStoreImpl) GetMetadata() (subsystems.BigSegmentStoreMetadata, error) {
+	return guarded(store.breaker, func() (subsystems.BigSegmentStoreMetadata, error) {
+		return retried(store.retrier, store.getMetadata)
+	})
+}
+
+func (store *redisBigSegmentStoreImpl) getMetadata() (subsystems.BigSegmentStoreMetadata, error) {
 	c := store.getConn()
 	defer c.Close() //nolint:errcheck
 
@@ -61,6 +74,16 @@ func (store *redisBigSegmentStoreImpl) GetMetadata() (subsystems.BigSegmentStore
 
 func (store *redisBigSegmentStoreImpl) GetMembership(
 	contextHashKey string,
+) (subsystems.BigSegmentMembership, error) {
+	return guarded(store.breaker, func() (subsystems.BigSegmentMembership, error) {
+		return retried(store.retrier, func() (subsystems.BigSegmentMembership, error) {
+			return store.getMembership(contextHashKey)
+		})
+	})
+}
+
+func (store *redisBigSegmentStoreImpl) getMembership(
+	contextHashKey string,
 ) (subsystems.BigSegmentMembership, error) {
 	c := store.getConn()
 	defer c.Close() //nolint:errcheck
@@ -77,12 +100,28 @@ func (store *redisBigSegmentStoreImpl) GetMembership(
 	return ldstoreimpl.NewBigSegmentMembershipFromSegmentRefs(includedRefs, excludedRefs), nil
 }
 
+// checkAvailable is used by the circuit breaker to find out whether Redis is available again.
+func (store *redisBigSegmentStoreImpl) checkAvailable() bool {
+	c := store.getConn()
+	defer c.Close() //nolint:errcheck
+	_, err := c.Do("PING")
+	return err == nil
+}
+
 func (store *redisBigSegmentStoreImpl) Close() error {
+	store.idleConns.stop()
+	logPoolStatsOnClose(store.pool, store.loggers)
 	return store.pool.Close()
 }
 
+// PoolStats returns the current state of the store's connection pool, or false if the pool does
+// not provide statistics. See PoolWithStats.
+func (store *redisBigSegmentStoreImpl) PoolStats() (PoolStats, bool) {
+	return getPoolStats(store.pool)
+}
+
 func (store *redisBigSegmentStoreImpl) getConn() r.Conn {
-	return store.pool.Get()
+	return getPoolConn(store.pool, store.loggers)
 }
 
 func bigSegmentsSyncTimeKey(prefix string) string {
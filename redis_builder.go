@@ -1,7 +1,11 @@
 package ldredis
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	r "github.com/gomodule/redigo/redis"
 
@@ -86,10 +90,10 @@ func BigSegmentStore() *StoreBuilder[subsystems.BigSegmentStore] {
 // In this example, the main data store uses a Redis host called "host1", and the Big Segment
 // store uses a Redis host called "host2":
 //
-//     config.DataStore = ldcomponents.PersistentDataStore(
-//         ldredis.DataStore().URL("redis://host1:6379")
-//     config.BigSegments = ldcomponents.BigSegments(
-//         ldredis.DataStore().URL("redis://host2:6379")
+//	config.DataStore = ldcomponents.PersistentDataStore(
+//	    ldredis.DataStore().URL("redis://host1:6379")
+//	config.BigSegments = ldcomponents.BigSegments(
+//	    ldredis.DataStore().URL("redis://host2:6379")
 //
 // Note that the SDK also has its own options related to data storage that are configured
 // at a different level, because they are independent of what database is being used. For
@@ -102,13 +106,56 @@ func BigSegmentStore() *StoreBuilder[subsystems.BigSegmentStore] {
 type StoreBuilder[T any] struct {
 	builderOptions builderOptions
 	factory        func(*StoreBuilder[T], subsystems.ClientContext) (T, error)
+	lock           sync.Mutex
+	builtPool      Pool
+	builtStore     *redisDataStoreImpl
 }
 
 type builderOptions struct {
-	prefix      string
-	pool        Pool
-	url         string
-	dialOptions []r.DialOption
+	prefix              string
+	pool                Pool
+	url                 string
+	dialOptions         []r.DialOption
+	circuitBreaker      circuitBreakerOptions
+	readRetry           readRetryOptions
+	credentialsProvider CredentialsProvider
+	tls                 tlsOptions
+	readOnly            ReadOnlyMode
+	sharedPool          *SharedPool
+	poolLimits          poolLimitsOptions
+	startupCheckTimeout time.Duration
+	scan                scanOptions
+	initChunkSize       int
+	initDiffMode        InitDiffMode
+	staleAfter          time.Duration
+	heartbeatInterval   time.Duration
+	instanceID          string
+	leaseTTL            time.Duration
+}
+
+// effectiveURL returns the URL to connect to, taking into account any other options that affect it.
+func (o builderOptions) effectiveURL() string {
+	if o.tls.isEnabled() {
+		return withTLSScheme(o.url)
+	}
+	return o.url
+}
+
+// endpointType describes how the store connects to Redis, for DescribeConfiguration.
+func (o builderOptions) endpointType() string {
+	switch {
+	case o.pool != nil:
+		return "custom"
+	case isUnixSocketURL(o.url):
+		return "unix"
+	default:
+		return "tcp"
+	}
+}
+
+// usesTLS returns true if connections created from these options use TLS.
+func (o builderOptions) usesTLS() bool {
+	return strings.HasPrefix(o.effectiveURL(), "rediss://")
 }
 
 // Prefix specifies a string that should be prepended to all Redis keys used by the data store.
@@ -127,6 +174,9 @@ func (b *StoreBuilder[T]) Prefix(prefix string) *StoreBuilder[T] {
 // the redis:// syntax (https://www.iana.org/assignments/uri-schemes/prov/redis), which can include a
 // password and a database number, as well as rediss://
 // (https://www.iana.org/assignments/uri-schemes/prov/rediss), which enables TLS.
+//
+// To connect to a Unix domain socket, use a URL of the form
+// unix://[username:password@]/path/to/redis.sock[?db=number], or use SocketPath.
 func (b *StoreBuilder[T]) URL(url string) *StoreBuilder[T] {
 	if url == "" {
 		url = DefaultURL
@@ -140,6 +190,15 @@ func (b *StoreBuilder[T]) HostAndPort(host string, port int) *StoreBuilder[T] {
 	return b.URL(fmt.Sprintf("redis://%s:%d", host, port))
 }
 
+// SocketPath is a shortcut for connecting to Redis through a Unix domain socket at the specified
+// path. It replaces any address specified with URL or HostAndPort.
+//
+// To select a database or provide a password, use DialOptions with DialDatabase or DialPassword,
+// or use URL with a unix:// URL instead.
+func (b *StoreBuilder[T]) SocketPath(path string) *StoreBuilder[T] {
+	return b.URL(unixSocketURL(path))
+}
+
 // Pool specifies that the data store should use a specific connection pool configuration. If not
 // specified, it will create a default configuration (see package description). Specifying this
 // option will cause any address specified with URL or HostAndPort to be ignored.
@@ -153,6 +212,43 @@ func (b *StoreBuilder[T]) Pool(pool *r.Pool) *StoreBuilder[T] {
 	return b
 }
 
+// PoolLimits changes the limits of the connection pool that the store creates. If maxActive,
+// maxIdle, or idleTimeout is zero or negative, the default is used: a maximum of 16 connections, a
+// maximum of 20 idle connections, and an idle timeout of 5 minutes.
+//
+// This option is ignored if you specify a connection pool with Pool or PoolInterface.
+func (b *StoreBuilder[T]) PoolLimits(maxActive, maxIdle int, idleTimeout time.Duration) *StoreBuilder[T] {
+	b.builderOptions.poolLimits.maxActive = maxActive
+	b.builderOptions.poolLimits.maxIdle = maxIdle
+	b.builderOptions.poolLimits.idleTimeout = idleTimeout
+	return b
+}
+
+// MinIdleConnections makes the store open the specified number of connections when it is built,
+// and keep at least that many idle connections open afterward, so that requests do not have to
+// wait for a new connection to be dialed. Idle connections are replenished every 10 seconds.
+//
+// The number must not be greater than the pool's maximum number of connections or idle
+// connections (see PoolLimits). If the connections can't be opened when the store is built, a
+// warning is logged and the store keeps trying in the background; use StartupCheck to make Build
+// fail instead. By default, or if n is zero, connections are only opened when they are needed.
+// This option is ignored if you specify a connection pool with Pool or PoolInterface.
+func (b *StoreBuilder[T]) MinIdleConnections(n int) *StoreBuilder[T] {
+	b.builderOptions.poolLimits.minIdle = n
+	return b
+}
+
+// SharedPool specifies that the store should use a connection pool that is shared with other stores.
+// See [SharedPool] for details. Specifying this option will cause any connection options of this
+// builder, such as URL, Pool, or DialOptions, to be ignored.
+//
+// When the store is closed, it releases its reference to the shared pool rather than closing it.
+// Building a store with a SharedPool that has already been fully closed returns [ErrSharedPoolClosed].
+func (b *StoreBuilder[T]) SharedPool(pool *SharedPool) *StoreBuilder[T] {
+	b.builderOptions.sharedPool = pool
+	return b
+}
+
 // PoolInterface is equivalent to Pool, but uses an interface type rather than a concrete
 // implementation type. This allows implementation of custom behaviors for connection management.
 func (b *StoreBuilder[T]) PoolInterface(pool Pool) *StoreBuilder[T] {
@@ -160,30 +256,354 @@ func (b *StoreBuilder[T]) PoolInterface(pool Pool) *StoreBuilder[T] {
 	return b
 }
 
+// CredentialsProvider specifies a function that provides the Redis username and password. This is
+// useful if the credentials are stored in a secrets manager and can change while the application
+// is running.
+//
+// The function is called each time the store opens a new connection. If Redis rejects the
+// credentials, either when connecting or on an existing connection, the store discards its idle
+// connections, calls the function again, and retries the connection once, so that connections
+// using the new credentials replace the old ones without restarting the SDK.
+//
+// Credentials from the provider take precedence over DialPassword and DialUsername, but a
+// password in the URL takes precedence over both, so the URL should not include one. This option
+// is ignored if you specify a connection pool with Pool or PoolInterface.
+func (b *StoreBuilder[T]) CredentialsProvider(provider CredentialsProvider) *StoreBuilder[T] {
+	b.builderOptions.credentialsProvider = provider
+	return b
+}
+
 // DialOptions specifies any of the advanced Redis connection options supported by Redigo, such as
 // DialPassword.
 //
-//     import (
-//         redigo "github.com/garyburd/redigo/redis"
-//         ldredis "github.com/launchdarkly/go-server-sdk-redis-redigo/v3"
-//     )
-//     config.DataSource = ldcomponents.PersistentDataStore(
-//         ldredis.DataStore().DialOptions(redigo.DialPassword("verysecure123")),
-//     )
+//	import (
+//	    redigo "github.com/garyburd/redigo/redis"
+//	    ldredis "github.com/launchdarkly/go-server-sdk-redis-redigo/v3"
+//	)
+//	config.DataSource = ldcomponents.PersistentDataStore(
+//	    ldredis.DataStore().DialOptions(redigo.DialPassword("verysecure123")),
+//	)
+//
 // Note that some Redis client features can also be specified as part of the URL: see  URL().
 func (b *StoreBuilder[T]) DialOptions(options ...r.DialOption) *StoreBuilder[T] {
 	b.builderOptions.dialOptions = options
 	return b
 }
 
+// CircuitBreaker enables a circuit breaker for Redis operations.
+//
+// When Redis is degraded, every operation otherwise waits for a pooled connection and then fails,
+// which adds load to Redis at the worst possible time. With a circuit breaker, after
+// failureThreshold consecutive operations have failed, the store stops trying to access Redis for
+// the specified cooldown period, and all operations fail immediately with [ErrCircuitOpen]. When the
+// cooldown period ends, the next operation first checks whether Redis is available, in the same way
+// as the data store's IsStoreAvailable method; if so, normal operations resume, and if not, the
+// cooldown period starts again.
+//
+// State changes are logged, and can also be observed with [StoreBuilder.CircuitBreakerListener].
+//
+// The circuit breaker is disabled by default, or if failureThreshold is zero or negative. If
+// cooldown is zero or negative, [DefaultCircuitBreakerCooldown] is used.
+func (b *StoreBuilder[T]) CircuitBreaker(failureThreshold int, cooldown time.Duration) *StoreBuilder[T] {
+	b.builderOptions.circuitBreaker.failureThreshold = failureThreshold
+	b.builderOptions.circuitBreaker.cooldown = cooldown
+	return b
+}
+
+// CircuitBreakerListener specifies a function to be called whenever the state of the circuit
+// breaker changes, for instance to update a metric. It has no effect unless CircuitBreaker is also
+// used.
+//
+// The function is called synchronously from whichever store operation caused the change, so it
+// should return quickly.
+func (b *StoreBuilder[T]) CircuitBreakerListener(listener func(CircuitBreakerEvent)) *StoreBuilder[T] {
+	b.builderOptions.circuitBreaker.listener = listener
+	return b
+}
+
+// ReadRetry enables automatic retrying of read operations that fail with a transient error.
+//
+// This applies only to operations that do not modify data: for the data store, Get, GetAll, and
+// IsInitialized; for the Big Segment store, GetMetadata and GetMembership. Each attempt uses a
+// different connection from the pool, so for instance if Redis closed an idle connection, the
+// retry will normally succeed on a fresh one.
+//
+// An operation is attempted at most maxAttempts times. The delay before the first retry is
+// initialBackoff, and it doubles for each subsequent retry up to maxBackoff. If these are zero or
+// negative, [DefaultReadRetryInitialBackoff] and [DefaultReadRetryMaxBackoff] are used.
+//
+// By default, only the errors described in [IsRetryableError] are retried; use
+// [StoreBuilder.ReadRetryCondition] to change this.
+//
+// Retries are disabled by default, or if maxAttempts is less than 2. If [StoreBuilder.CircuitBreaker]
+// is also used, all of the attempts for an operation count as a single success or failure.
+func (b *StoreBuilder[T]) ReadRetry(maxAttempts int, initialBackoff, maxBackoff time.Duration) *StoreBuilder[T] {
+	b.builderOptions.readRetry.maxAttempts = maxAttempts
+	b.builderOptions.readRetry.initialBackoff = initialBackoff
+	b.builderOptions.readRetry.maxBackoff = maxBackoff
+	return b
+}
+
+// ReadRetryCondition specifies a function that decides whether a failed read operation should be
+// retried, replacing the default of [IsRetryableError]. It has no effect unless ReadRetry is also
+// used. Setting it to nil restores the default.
+func (b *StoreBuilder[T]) ReadRetryCondition(isRetryable func(error) bool) *StoreBuilder[T] {
+	b.builderOptions.readRetry.isRetryable = isRetryable
+	return b
+}
+
+// TLSCACertFile specifies a file containing one or more PEM-encoded CA certificates, which will be
+// used instead of the system's root certificates to verify the Redis server's certificate.
+//
+// Setting any of the TLS options (TLSCACertFile, TLSClientCertFiles, TLSServerName, or
+// TLSMinVersion) enables TLS, even if the URL uses the redis:// scheme rather than rediss://.
+// Certificate files are read when a new connection is opened, and are read again if they have
+// been modified since then, so certificates can be rotated without restarting the SDK. The TLS
+// options are ignored if you specify a connection pool with Pool or PoolInterface, and they
+// replace any configuration that was specified with redigo.DialTLSConfig in DialOptions.
+func (b *StoreBuilder[T]) TLSCACertFile(path string) *StoreBuilder[T] {
+	b.builderOptions.tls.caCertFile = path
+	return b
+}
+
+// TLSClientCertFiles specifies a PEM-encoded client certificate and private key, for Redis servers
+// that require mutual TLS authentication. See TLSCACertFile for more about the TLS options.
+func (b *StoreBuilder[T]) TLSClientCertFiles(certFile, keyFile string) *StoreBuilder[T] {
+	b.builderOptions.tls.clientCertFile = certFile
+	b.builderOptions.tls.clientKeyFile = keyFile
+	return b
+}
+
+// TLSServerName specifies the hostname that the Redis server's certificate is expected to have,
+// if it is different from the hostname in the URL. See TLSCACertFile for more about the TLS options.
+func (b *StoreBuilder[T]) TLSServerName(serverName string) *StoreBuilder[T] {
+	b.builderOptions.tls.serverName = serverName
+	return b
+}
+
+// TLSMinVersion specifies the minimum TLS version to accept, such as tls.VersionTLS13. If not
+// specified, the default for Go's crypto/tls package is used. See TLSCACertFile for more about the
+// TLS options.
+func (b *StoreBuilder[T]) TLSMinVersion(version uint16) *StoreBuilder[T] {
+	b.builderOptions.tls.minVersion = version
+	return b
+}
+
+// ReadOnly prevents the data store from writing to Redis. This is useful for applications that use
+// the SDK in daemon mode, where only the Relay Proxy should update Redis: a misconfigured
+// application could otherwise overwrite the flag data with an outdated Init.
+//
+// With [ReadOnlyReject], Init and Upsert fail with [ErrReadOnly] and log an error. With
+// [ReadOnlyIgnore], they do nothing except log a warning, and report success. The default is
+// [ReadWrite]. This option has no effect on the Big Segment store, which never writes to Redis.
+func (b *StoreBuilder[T]) ReadOnly(mode ReadOnlyMode) *StoreBuilder[T] {
+	b.builderOptions.readOnly = mode
+	return b
+}
+
+// InitChunkSize makes Init write up to n items with each HSET command, and write large collections
+// in chunks of that size. This requires Redis 4.0 or later, since earlier versions of HSET only
+// accept one item.
+//
+// Each collection that has no more than n items is written in the same transaction that replaces
+// the existing data, as before. A larger collection is first written to a temporary key in chunks
+// of n items, with several commands pipelined at a time, and the transaction then renames the
+// temporary key, so that the data is still replaced atomically but Redis is not blocked by one very
+// large transaction. If an Init does not finish, for instance because the process stopped, its
+// temporary keys expire after an hour.
+//
+// If n is zero or negative, which is the default, Init writes each item with its own HSET command
+// in the transaction that replaces the existing data.
+func (b *StoreBuilder[T]) InitChunkSize(n int) *StoreBuilder[T] {
+	b.builderOptions.initChunkSize = n
+	return b
+}
+
+// InitDiff specifies whether Init replaces all of the stored data, or only writes the changes.
+//
+// By default ([InitReplaceAll]), Init deletes and rewrites every collection, even if the data is
+// nearly the same as what is already stored, as it usually is when the SDK reconnects to
+// LaunchDarkly. With [InitDiffByVersion] or [InitDiffByHash], Init first reads the stored items,
+// and then writes only the items that were added or changed and deletes only the items that were
+// removed, which reduces the load on Redis replication and on the append-only file. The changes and
+// the initialized flag are written in one transaction. If the stored data is modified while it is
+// being compared, the comparison is repeated, and after several such attempts Init replaces all of
+// the data instead.
+func (b *StoreBuilder[T]) InitDiff(mode InitDiffMode) *StoreBuilder[T] {
+	b.builderOptions.initDiffMode = mode
+	return b
+}
+
+// ScanLargeCollections makes GetAll read a collection with HSCAN, in batches of batchSize items,
+// instead of with a single HGETALL, if the collection has more than threshold items. This avoids
+// very large Redis replies and the memory spikes that they cause, at the cost of one HLEN command
+// for each GetAll and more round trips for large collections.
+//
+// If threshold or batchSize is zero or negative, [DefaultScanThreshold] or [DefaultScanBatchSize]
+// is used. By default, GetAll always uses HGETALL. The batch size is also used by the iterator
+// described in [IterableDataStore], whether or not this option is enabled. A [Snapshotter] always
+// uses HGETALL, so that it can read all kinds of data in one transaction.
+func (b *StoreBuilder[T]) ScanLargeCollections(threshold, batchSize int) *StoreBuilder[T] {
+	b.builderOptions.scan = scanOptions{enabled: true, threshold: threshold, batchSize: batchSize}
+	return b
+}
+
+// StaleAfter sets the maximum age of the data, after which the data store reports that the data is
+// stale.
+//
+// Every Init and Upsert that changes the data records the time of the change. If a threshold is
+// set, the store checks the age of the data periodically, and logs a warning if the data has not
+// changed for longer than the threshold, and a message when it has changed again. This is meant for
+// an SDK in daemon mode, which only reads the data that another process such as the Relay Proxy
+// writes: stale data means that the other process has probably stopped. Choose a threshold that is
+// longer than the longest time during which the data can legitimately be unchanged, or use a
+// writer that periodically calls Init.
+//
+// Stale data does not make the store unavailable, so the SDK keeps using it. Whether the data is
+// stale can be queried with [StoreBuilder.Freshness], or as described in [FreshnessReader]. The data
+// is never considered stale if it was written by an older version of this integration, which did
+// not record the time. The default is zero, which means that the age is not checked.
+func (b *StoreBuilder[T]) StaleAfter(threshold time.Duration) *StoreBuilder[T] {
+	b.builderOptions.staleAfter = threshold
+	return b
+}
+
+// WriterHeartbeat makes the data store periodically record that it is writing to Redis, so that
+// the writers that are using the same prefix can be listed as described in [WriterLister].
+//
+// After its first Init, the store writes a record with its instance ID, the SDK version, and the
+// current time at the specified interval. The record expires after three intervals, so a writer
+// that has stopped without closing the store is no longer listed after that time. Closing the
+// store deletes the record. A store that never calls Init, such as one that is used in daemon mode,
+// does not send heartbeats. The default is zero, which means that heartbeats are not sent.
+func (b *StoreBuilder[T]) WriterHeartbeat(interval time.Duration) *StoreBuilder[T] {
+	b.builderOptions.heartbeatInterval = interval
+	return b
+}
+
+// InstanceID sets the ID that identifies this data store in its writer heartbeats and as the
+// holder of the writer lease. See [StoreBuilder.WriterHeartbeat] and [StoreBuilder.WriterLease].
+// The default is the host name followed by a random suffix.
+func (b *StoreBuilder[T]) InstanceID(id string) *StoreBuilder[T] {
+	b.builderOptions.instanceID = id
+	return b
+}
+
+// WriterLease makes data stores that use the same prefix take turns writing to Redis, so that
+// several instances that call Init at the same time, such as when they all reconnect to
+// LaunchDarkly, do not overwrite each other's data.
+//
+// Only the store that holds the lease writes the data. The others only read the data, but keep a
+// copy in memory of what they were asked to write, so that a store that acquires the lease can
+// immediately write the latest data, including any updates that it received while another store
+// held the lease. A store tries to acquire the lease when it is built and whenever it is asked to
+// write while no store holds the lease, and the holder renews it at intervals of a third of the
+// specified time to live. If the holder stops without releasing the lease, the lease expires after
+// that time and another store acquires it. Each holder gets a fencing token that is greater than any earlier token, and every
+// write checks in its transaction that no holder with a greater token has written in the meantime,
+// so a holder that has lost the lease without noticing, for instance because it was paused, cannot
+// overwrite newer data. The default is zero, which means that every store writes. Build returns an
+// error if the time to live is less than MinWriterLeaseTTL.
+func (b *StoreBuilder[T]) WriterLease(ttl time.Duration) *StoreBuilder[T] {
+	b.builderOptions.leaseTTL = ttl
+	return b
+}
+
+// StartupCheck makes Build verify that it can connect to Redis, authenticate if credentials were
+// specified, and get a response to a PING command, within the specified timeout. If not, Build
+// returns a descriptive error instead of a store whose operations would all fail. By default, or
+// if timeout is zero or negative, there is no startup check.
+//
+// Build always validates the configuration, such as the URL, whether or not this option is used.
+func (b *StoreBuilder[T]) StartupCheck(timeout time.Duration) *StoreBuilder[T] {
+	b.builderOptions.startupCheckTimeout = timeout
+	return b
+}
+
 // Build is called internally by the SDK.
 func (b *StoreBuilder[T]) Build(context subsystems.ClientContext) (T, error) {
 	return b.factory(b, context)
 }
 
+// PoolStats returns the current state of the connection pool used by the store that was most
+// recently built by this builder, or false if no store has been built yet or the pool does not
+// provide statistics. See PoolWithStats.
+//
+// This is a convenience for diagnostics, since the SDK does not provide access to the store itself.
+func (b *StoreBuilder[T]) PoolStats() (PoolStats, bool) {
+	pool := b.getBuiltPool()
+	if pool == nil {
+		return PoolStats{}, false
+	}
+	return getPoolStats(pool)
+}
+
 // DescribeConfiguration is used internally by the SDK to inspect the configuration.
+//
+// The result is an object with the following properties. It never includes the Redis address,
+// credentials, or prefix.
+//   - "dataStoreType": always "Redis".
+//   - "endpointType": "tcp", "unix" for a Unix domain socket, or "custom" if a pool was specified
+//     with Pool or PoolInterface.
+//   - "customPool": true if a pool was specified with Pool or PoolInterface.
+//   - "sharedPool": true if a SharedPool is used.
+//   - "tls": true if connections use TLS. This is false for a custom pool, since its connection
+//     options are unknown.
+//   - "usingDefaultPrefix": true if the prefix is DefaultPrefix.
+//   - "poolLimits": the pool's maxActive, maxIdle, idleTimeoutMillis, and wait settings, if known,
+//     and minIdle if the store created the pool.
+//   - "poolStats": the current statistics of the pool, if a store has been built (see PoolStats).
 func (b *StoreBuilder[T]) DescribeConfiguration() ldvalue.Value {
-	return ldvalue.String("Redis")
+	options := b.builderOptions
+	if options.sharedPool != nil {
+		options = options.sharedPool.options
+	}
+	pool := options.pool
+	if built, ok := b.getBuiltPool().(*r.Pool); ok && pool == nil {
+		pool = built
+	}
+
+	builder := ldvalue.ObjectBuild().
+		SetString("dataStoreType", "Redis").
+		SetString("endpointType", options.endpointType()).
+		SetBool("customPool", options.pool != nil).
+		SetBool("sharedPool", b.builderOptions.sharedPool != nil).
+		SetBool("tls", options.pool == nil && options.usesTLS()).
+		SetBool("usingDefaultPrefix", b.builderOptions.prefix == DefaultPrefix)
+	if limits, ok := poolLimitsValue(pool, options.poolLimits); ok {
+		builder.Set("poolLimits", limits)
+	}
+	if stats, ok := b.PoolStats(); ok {
+		builder.Set("poolStats", poolStatsValue(stats))
+	}
+	return builder.Build()
+}
+
+// Freshness returns how recently the data was written, and whether it is stale, for the data store
+// that was most recently built by this builder. It returns an error if no data store has been built
+// yet, or if the time of the last update cannot be read. See StaleAfter and FreshnessReader.
+//
+// This is a convenience for monitoring, since the SDK does not provide access to the store itself.
+func (b *StoreBuilder[T]) Freshness() (DataFreshness, error) {
+	b.lock.Lock()
+	store := b.builtStore
+	b.lock.Unlock()
+	if store == nil {
+		return DataFreshness{}, errors.New("no data store has been built")
+	}
+	return store.GetFreshness()
+}
+
+func (b *StoreBuilder[T]) getBuiltPool() Pool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.builtPool
+}
+
+func (b *StoreBuilder[T]) setBuiltPool(pool Pool) {
+	b.lock.Lock()
+	b.builtPool = pool
+	b.lock.Unlock()
 }
 
 // Pool is an interface representing a Redis connection pool.
@@ -207,7 +627,28 @@ func createPersistentDataStore(
 	builder *StoreBuilder[subsystems.PersistentDataStore],
 	clientContext subsystems.ClientContext,
 ) (subsystems.PersistentDataStore, error) {
-	store := newRedisDataStoreImpl(builder.builderOptions, clientContext.GetLogging().Loggers)
+	options, err := builder.builderOptions.prepare(clientContext.GetLogging().Loggers)
+	if err != nil {
+		return nil, err
+	}
+	store := newRedisDataStoreImpl(options, clientContext.GetLogging().Loggers)
+	store.sdk = sdkInfoFromContext(clientContext)
+	store.instanceID = options.instanceID
+	if store.instanceID == "" {
+		store.instanceID = defaultInstanceID()
+	}
+	store.heartbeat = newWriterHeartbeat(store, options.heartbeatInterval)
+	store.lease = newWriterLease(store, options.leaseTTL)
+	if err := options.runStartupCheck(store.pool); err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	store.lease.start()
+	store.startStalenessChecks()
+	builder.setBuiltPool(store.pool)
+	builder.lock.Lock()
+	builder.builtStore = store
+	builder.lock.Unlock()
 	return store, nil
 }
 
@@ -215,6 +656,15 @@ func createBigSegmentStore(
 	builder *StoreBuilder[subsystems.BigSegmentStore],
 	clientContext subsystems.ClientContext,
 ) (subsystems.BigSegmentStore, error) {
-	store := newRedisBigSegmentStoreImpl(builder.builderOptions, clientContext.GetLogging().Loggers)
+	options, err := builder.builderOptions.prepare(clientContext.GetLogging().Loggers)
+	if err != nil {
+		return nil, err
+	}
+	store := newRedisBigSegmentStoreImpl(options, clientContext.GetLogging().Loggers)
+	if err := options.runStartupCheck(store.pool); err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	builder.setBuiltPool(store.pool)
 	return store, nil
 }
@@ -0,0 +1,206 @@
+package ldredis
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// DefaultCircuitBreakerCooldown is the cooldown period that is used by StoreBuilder.CircuitBreaker
+// if the specified cooldown is zero or negative.
+const DefaultCircuitBreakerCooldown = 10 * time.Second
+
+// ErrCircuitOpen is the error returned by store operations that were not attempted because the
+// circuit breaker is open. See StoreBuilder.CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open; Redis operation was not attempted")
+
+// CircuitBreakerState is the state of a store's circuit breaker. See StoreBuilder.CircuitBreaker.
+type CircuitBreakerState int
+
+const (
+	// CircuitClosed means that operations are being performed normally.
+	CircuitClosed CircuitBreakerState = iota
+	// CircuitOpen means that operations are failing immediately with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen means that the cooldown period has ended, and the store is checking whether
+	// Redis is available again before it resumes normal operations.
+	CircuitHalfOpen
+)
+
+// String returns a description of the state, such as "closed".
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerEvent describes a change in the state of a store's circuit breaker. It is passed to
+// the function that was specified with StoreBuilder.CircuitBreakerListener.
+type CircuitBreakerEvent struct {
+	// OldState is the previous state.
+	OldState CircuitBreakerState
+	// NewState is the current state.
+	NewState CircuitBreakerState
+	// ConsecutiveFailures is the number of operations that had failed in a row when the state changed.
+	ConsecutiveFailures int
+	// LastError is the most recent error that caused an operation to fail, if any.
+	LastError error
+}
+
+type circuitBreakerOptions struct {
+	failureThreshold int
+	cooldown         time.Duration
+	listener         func(CircuitBreakerEvent)
+}
+
+// circuitBreaker implements the behavior described in StoreBuilder.CircuitBreaker. It is used by
+// both the data store and the Big Segment store; each store has its own instance.
+type circuitBreaker struct {
+	options   circuitBreakerOptions
+	probe     func() bool
+	loggers   ldlog.Loggers
+	lock      sync.Mutex
+	state     CircuitBreakerState
+	failures  int
+	lastError error
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+func newCircuitBreaker(options circuitBreakerOptions, probe func() bool, loggers ldlog.Loggers) *circuitBreaker {
+	if options.failureThreshold <= 0 {
+		return nil
+	}
+	if options.cooldown <= 0 {
+		options.cooldown = DefaultCircuitBreakerCooldown
+	}
+	return &circuitBreaker{
+		options: options,
+		probe:   probe,
+		loggers: loggers,
+		now:     time.Now,
+	}
+}
+
+// guarded performs an operation if the circuit breaker allows it, and records its result. If the
+// circuit breaker is nil, the operation is always performed.
+func guarded[T any](cb *circuitBreaker, action func() (T, error)) (T, error) {
+	if cb == nil {
+		return action()
+	}
+	if err := cb.allow(); err != nil {
+		var empty T
+		return empty, err
+	}
+	result, err := action()
+	cb.record(err)
+	return result, err
+}
+
+// guardedErr is equivalent to guarded, for operations that do not return a result.
+func guardedErr(cb *circuitBreaker, action func() error) error {
+	_, err := guarded(cb, func() (struct{}, error) { return struct{}{}, action() })
+	return err
+}
+
+// allow returns nil if an operation can be attempted, or ErrCircuitOpen if not. If the cooldown
+// period has ended, it checks whether Redis is available before allowing the operation; while
+// that check is in progress, other operations fail immediately.
+func (cb *circuitBreaker) allow() error {
+	cb.lock.Lock()
+	switch cb.state {
+	case CircuitClosed:
+		cb.lock.Unlock()
+		return nil
+	case CircuitHalfOpen:
+		cb.lock.Unlock()
+		return ErrCircuitOpen
+	}
+	if cb.now().Sub(cb.openedAt) < cb.options.cooldown {
+		cb.lock.Unlock()
+		return ErrCircuitOpen
+	}
+	event := cb.setState(CircuitHalfOpen)
+	cb.lock.Unlock()
+	cb.notify(event)
+
+	available := cb.probe()
+
+	cb.lock.Lock()
+	if available {
+		cb.failures, cb.lastError = 0, nil
+		event = cb.setState(CircuitClosed)
+	} else {
+		cb.openedAt = cb.now()
+		event = cb.setState(CircuitOpen)
+	}
+	cb.lock.Unlock()
+	cb.notify(event)
+	if !available {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record updates the failure count after an operation.
+func (cb *circuitBreaker) record(err error) {
+	cb.lock.Lock()
+	if err == nil {
+		cb.failures, cb.lastError = 0, nil
+		cb.lock.Unlock()
+		return
+	}
+	cb.failures++
+	cb.lastError = err
+	if cb.state != CircuitClosed || cb.failures < cb.options.failureThreshold {
+		cb.lock.Unlock()
+		return
+	}
+	cb.openedAt = cb.now()
+	event := cb.setState(CircuitOpen)
+	cb.lock.Unlock()
+	cb.notify(event)
+}
+
+// setState changes the state and returns an event describing the change. The caller must hold the
+// lock.
+func (cb *circuitBreaker) setState(newState CircuitBreakerState) CircuitBreakerEvent {
+	event := CircuitBreakerEvent{
+		OldState:            cb.state,
+		NewState:            newState,
+		ConsecutiveFailures: cb.failures,
+		LastError:           cb.lastError,
+	}
+	cb.state = newState
+	return event
+}
+
+// notify logs a state change and passes it to the listener, if any. The caller must not hold the
+// lock, so that the listener can safely call other store methods.
+func (cb *circuitBreaker) notify(event CircuitBreakerEvent) {
+	switch event.NewState {
+	case CircuitOpen:
+		if event.OldState == CircuitHalfOpen {
+			cb.loggers.Warnf("Redis is still unavailable; circuit breaker will stay open for %s", cb.options.cooldown)
+		} else {
+			cb.loggers.Warnf("Circuit breaker opened after %d consecutive failures (last error: %s); operations will fail for %s",
+				event.ConsecutiveFailures, event.LastError, cb.options.cooldown)
+		}
+	case CircuitHalfOpen:
+		cb.loggers.Info("Circuit breaker cooldown ended; checking whether Redis is available")
+	case CircuitClosed:
+		cb.loggers.Warn("Redis is available again; circuit breaker closed")
+	}
+	if cb.options.listener != nil {
+		cb.options.listener(event)
+	}
+}
@@ -0,0 +1,201 @@
+package ldredis
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type circuitBreakerTestParams struct {
+	faults  *ldredistest.FaultPool
+	gets    *countingPool
+	mockLog *ldlogtest.MockLog
+	events  []CircuitBreakerEvent
+	now     time.Time
+	lock    sync.Mutex
+}
+
+// countingPool counts how many times a connection was requested, so tests can verify that an
+// operation did not try to access Redis.
+type countingPool struct {
+	Pool
+	count int
+	lock  sync.Mutex
+}
+
+func (p *countingPool) Get() r.Conn {
+	p.lock.Lock()
+	p.count++
+	p.lock.Unlock()
+	return p.Pool.Get()
+}
+
+func (p *countingPool) getCount() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.count
+}
+
+func newCircuitBreakerTestParams() *circuitBreakerTestParams {
+	p := &circuitBreakerTestParams{
+		faults:  ldredistest.NewFaultPool(ldredistest.NewServer().NewPool()),
+		mockLog: ldlogtest.NewMockLog(),
+		now:     time.Now(),
+	}
+	p.gets = &countingPool{Pool: p.faults}
+	return p
+}
+
+func (p *circuitBreakerTestParams) configure(b *StoreBuilder[subsystems.PersistentDataStore]) *StoreBuilder[subsystems.PersistentDataStore] {
+	return b.PoolInterface(p.gets).
+		CircuitBreaker(3, time.Minute).
+		CircuitBreakerListener(func(e CircuitBreakerEvent) {
+			p.lock.Lock()
+			p.events = append(p.events, e)
+			p.lock.Unlock()
+		})
+}
+
+func (p *circuitBreakerTestParams) buildDataStore(t *testing.T) *redisDataStoreImpl {
+	var context subsystems.BasicClientContext
+	context.Logging.Loggers = p.mockLog.Loggers
+	store, err := p.configure(DataStore()).Build(context)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	impl := store.(*redisDataStoreImpl)
+	impl.breaker.now = func() time.Time { return p.now }
+	return impl
+}
+
+func (p *circuitBreakerTestParams) eventStates() []CircuitBreakerState {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var ret []CircuitBreakerState
+	for _, e := range p.events {
+		ret = append(ret, e.NewState)
+	}
+	return ret
+}
+
+func TestCircuitBreakerIsDisabledByDefault(t *testing.T) {
+	store, err := DataStore().PoolInterface(ldredistest.NewServer().NewPool()).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+	assert.Nil(t, store.(*redisDataStoreImpl).breaker)
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	p := newCircuitBreakerTestParams()
+	defer p.mockLog.DumpIfTestFailed(t)
+	store := p.buildDataStore(t)
+
+	p.faults.OnCommand("*").Fail(nil)
+	for i := 0; i < 3; i++ {
+		_, err := store.Get(ldstoreimpl.Features(), "flag")
+		assert.Equal(t, ldredistest.ErrInjectedFault, err)
+	}
+	assert.Equal(t, []CircuitBreakerState{CircuitOpen}, p.eventStates())
+	assert.Equal(t, 3, p.events[0].ConsecutiveFailures)
+	assert.Equal(t, ldredistest.ErrInjectedFault, p.events[0].LastError)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Circuit breaker opened after 3 consecutive failures")
+
+	getsBefore := p.gets.getCount()
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, ErrCircuitOpen, err)
+	_, err = store.GetAll(ldstoreimpl.Features())
+	assert.Equal(t, ErrCircuitOpen, err)
+	_, err = store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 1).Item)
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, ErrCircuitOpen, store.Init(makeTestFlagData()))
+	assert.False(t, store.IsInitialized())
+	assert.False(t, store.IsStoreAvailable())
+	assert.Equal(t, getsBefore, p.gets.getCount())
+}
+
+func TestCircuitBreakerSuccessResetsFailureCount(t *testing.T) {
+	p := newCircuitBreakerTestParams()
+	store := p.buildDataStore(t)
+
+	p.faults.OnCommand("*").Times(2).Fail(nil)
+	for i := 0; i < 2; i++ {
+		_, err := store.Get(ldstoreimpl.Features(), "flag")
+		assert.Error(t, err)
+	}
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+
+	p.faults.OnCommand("*").Times(2).Fail(nil)
+	for i := 0; i < 2; i++ {
+		_, err := store.Get(ldstoreimpl.Features(), "flag")
+		assert.Equal(t, ldredistest.ErrInjectedFault, err)
+	}
+	assert.Len(t, p.eventStates(), 0)
+}
+
+func TestCircuitBreakerStaysOpenIfProbeFails(t *testing.T) {
+	p := newCircuitBreakerTestParams()
+	defer p.mockLog.DumpIfTestFailed(t)
+	store := p.buildDataStore(t)
+
+	p.faults.OnCommand("*").Fail(nil)
+	for i := 0; i < 3; i++ {
+		_, _ = store.Get(ldstoreimpl.Features(), "flag")
+	}
+
+	p.now = p.now.Add(time.Minute)
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, []CircuitBreakerState{CircuitOpen, CircuitHalfOpen, CircuitOpen}, p.eventStates())
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "still unavailable")
+
+	// the cooldown period has restarted
+	p.faults.Clear()
+	p.now = p.now.Add(time.Second)
+	_, err = store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, ErrCircuitOpen, err)
+}
+
+func TestCircuitBreakerClosesIfProbeSucceeds(t *testing.T) {
+	p := newCircuitBreakerTestParams()
+	defer p.mockLog.DumpIfTestFailed(t)
+	store := p.buildDataStore(t)
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	p.faults.OnCommand("*").Fail(nil)
+	for i := 0; i < 3; i++ {
+		_, _ = store.Get(ldstoreimpl.Features(), "flag")
+	}
+
+	p.faults.Clear()
+	p.now = p.now.Add(time.Minute)
+	item, err := store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 1).Item.SerializedItem, item.SerializedItem)
+	assert.Equal(t, []CircuitBreakerState{CircuitOpen, CircuitHalfOpen, CircuitClosed}, p.eventStates())
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "circuit breaker closed")
+}
+
+func TestCircuitBreakerForBigSegmentStore(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, err := BigSegmentStore().PoolInterface(faults).CircuitBreaker(1, time.Minute).
+		Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	faults.OnCommand("SMEMBERS").Times(1).Fail(nil)
+	_, err = store.GetMembership("abc")
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+	_, err = store.GetMetadata()
+	assert.Equal(t, ErrCircuitOpen, err)
+}
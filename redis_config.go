@@ -0,0 +1,302 @@
+package ldredis
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+)
+
+// DefaultEnvPrefix is the prefix for environment variable names that is used by ConfigFromEnv if
+// no other prefix is specified.
+const DefaultEnvPrefix = "REDIS_"
+
+// Config is a set of Redis store options in a form that can be loaded from a configuration file,
+// for instance with encoding/json or a YAML library, or from environment variables with
+// ConfigFromEnv. Use DataStoreFromConfig or BigSegmentStoreFromConfig to create a StoreBuilder
+// with these options.
+//
+// Empty or zero values mean that the default for that option is used. Each field corresponds to a
+// StoreBuilder method, as described below; the environment variable that ConfigFromEnv uses for
+// the field is shown in brackets, without the prefix.
+type Config struct {
+	// URL is the Redis URL: see StoreBuilder.URL. [URL]
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// SocketPath is the path of a Unix domain socket: see StoreBuilder.SocketPath. It cannot be
+	// used together with URL. [SOCKET_PATH]
+	SocketPath string `json:"socketPath,omitempty" yaml:"socketPath,omitempty"`
+	// Prefix is the key prefix: see StoreBuilder.Prefix. [PREFIX]
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// Username is the Redis ACL username. A username in the URL takes precedence. [USERNAME]
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	// Password is the Redis password. A password in the URL takes precedence. [PASSWORD]
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// Database is the Redis database number. A database number in the URL takes precedence. [DATABASE]
+	Database int `json:"database,omitempty" yaml:"database,omitempty"`
+	// MaxActive is the maximum number of connections: see StoreBuilder.PoolLimits. [MAX_ACTIVE]
+	MaxActive int `json:"maxActive,omitempty" yaml:"maxActive,omitempty"`
+	// MaxIdle is the maximum number of idle connections: see StoreBuilder.PoolLimits. [MAX_IDLE]
+	MaxIdle int `json:"maxIdle,omitempty" yaml:"maxIdle,omitempty"`
+	// MinIdle is the minimum number of idle connections: see StoreBuilder.MinIdleConnections. [MIN_IDLE]
+	MinIdle int `json:"minIdle,omitempty" yaml:"minIdle,omitempty"`
+	// IdleTimeout is the idle connection timeout, in the format accepted by time.ParseDuration,
+	// such as "5m": see StoreBuilder.PoolLimits. [IDLE_TIMEOUT]
+	IdleTimeout string `json:"idleTimeout,omitempty" yaml:"idleTimeout,omitempty"`
+	// TLSCACertFile is the CA certificate file: see StoreBuilder.TLSCACertFile. [TLS_CA_CERT_FILE]
+	TLSCACertFile string `json:"tlsCACertFile,omitempty" yaml:"tlsCACertFile,omitempty"`
+	// TLSClientCertFile is the client certificate file: see StoreBuilder.TLSClientCertFiles. It
+	// requires TLSClientKeyFile. [TLS_CLIENT_CERT_FILE]
+	TLSClientCertFile string `json:"tlsClientCertFile,omitempty" yaml:"tlsClientCertFile,omitempty"`
+	// TLSClientKeyFile is the client key file: see StoreBuilder.TLSClientCertFiles. It requires
+	// TLSClientCertFile. [TLS_CLIENT_KEY_FILE]
+	TLSClientKeyFile string `json:"tlsClientKeyFile,omitempty" yaml:"tlsClientKeyFile,omitempty"`
+	// TLSServerName is the server name for TLS: see StoreBuilder.TLSServerName. [TLS_SERVER_NAME]
+	TLSServerName string `json:"tlsServerName,omitempty" yaml:"tlsServerName,omitempty"`
+	// ReadOnly is "reject" or "ignore" to enable read-only mode: see StoreBuilder.ReadOnly. [READ_ONLY]
+	ReadOnly string `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+}
+
+// configField describes how a Config field is named in a configuration file and in the environment.
+type configField struct {
+	name   string
+	envVar string
+}
+
+//nolint:gochecknoglobals
+var (
+	configURL               = configField{"url", "URL"}
+	configSocketPath        = configField{"socketPath", "SOCKET_PATH"}
+	configPrefix            = configField{"prefix", "PREFIX"}
+	configUsername          = configField{"username", "USERNAME"}
+	configPassword          = configField{"password", "PASSWORD"}
+	configDatabase          = configField{"database", "DATABASE"}
+	configMaxActive         = configField{"maxActive", "MAX_ACTIVE"}
+	configMaxIdle           = configField{"maxIdle", "MAX_IDLE"}
+	configMinIdle           = configField{"minIdle", "MIN_IDLE"}
+	configIdleTimeout       = configField{"idleTimeout", "IDLE_TIMEOUT"}
+	configTLSCACertFile     = configField{"tlsCACertFile", "TLS_CA_CERT_FILE"}
+	configTLSClientCertFile = configField{"tlsClientCertFile", "TLS_CLIENT_CERT_FILE"}
+	configTLSClientKeyFile  = configField{"tlsClientKeyFile", "TLS_CLIENT_KEY_FILE"}
+	configTLSServerName     = configField{"tlsServerName", "TLS_SERVER_NAME"}
+	configReadOnly          = configField{"readOnly", "READ_ONLY"}
+)
+
+// ConfigFromEnv reads a Config from environment variables whose names start with envPrefix, such
+// as REDIS_URL and REDIS_PREFIX if envPrefix is "REDIS_". If envPrefix is empty, [DefaultEnvPrefix]
+// is used. See Config for the variable names.
+//
+// Unset or empty variables are ignored. An error is returned if a variable has a value that is
+// not valid; the error names the variable but does not include its value, since it could contain
+// a password.
+func ConfigFromEnv(envPrefix string) (Config, error) {
+	if envPrefix == "" {
+		envPrefix = DefaultEnvPrefix
+	}
+	env := func(field configField) string { return os.Getenv(envPrefix + field.envVar) }
+	envInt := func(field configField) (int, error) {
+		s := env(field)
+		if s == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid Redis configuration: %s%s must be an integer", envPrefix, field.envVar)
+		}
+		return n, nil
+	}
+
+	config := Config{
+		URL:               env(configURL),
+		SocketPath:        env(configSocketPath),
+		Prefix:            env(configPrefix),
+		Username:          env(configUsername),
+		Password:          env(configPassword),
+		IdleTimeout:       env(configIdleTimeout),
+		TLSCACertFile:     env(configTLSCACertFile),
+		TLSClientCertFile: env(configTLSClientCertFile),
+		TLSClientKeyFile:  env(configTLSClientKeyFile),
+		TLSServerName:     env(configTLSServerName),
+		ReadOnly:          env(configReadOnly),
+	}
+	var err error
+	if config.Database, err = envInt(configDatabase); err != nil {
+		return Config{}, err
+	}
+	if config.MaxActive, err = envInt(configMaxActive); err != nil {
+		return Config{}, err
+	}
+	if config.MaxIdle, err = envInt(configMaxIdle); err != nil {
+		return Config{}, err
+	}
+	if config.MinIdle, err = envInt(configMinIdle); err != nil {
+		return Config{}, err
+	}
+	if err := config.validate(func(field configField) string { return envPrefix + field.envVar }); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Validate checks whether the configuration is valid, and returns a descriptive error if not. The
+// error never includes a password.
+func (c Config) Validate() error {
+	return c.validate(func(field configField) string { return field.name })
+}
+
+func (c Config) validate(fieldName func(configField) string) error {
+	invalid := func(field configField, format string, args ...interface{}) error {
+		return fmt.Errorf("invalid Redis configuration: %s %s", fieldName(field), fmt.Sprintf(format, args...))
+	}
+	if c.URL != "" && c.SocketPath != "" {
+		return invalid(configSocketPath, "cannot be used together with %s", fieldName(configURL))
+	}
+	if c.URL != "" {
+		if err := validateURL(c.URL); err != nil {
+			return invalid(configURL, "is not valid: %s", err)
+		}
+	}
+	if c.Database < 0 {
+		return invalid(configDatabase, "must not be negative")
+	}
+	if c.MaxActive < 0 {
+		return invalid(configMaxActive, "must not be negative")
+	}
+	if c.MaxIdle < 0 {
+		return invalid(configMaxIdle, "must not be negative")
+	}
+	if c.MinIdle < 0 {
+		return invalid(configMinIdle, "must not be negative")
+	}
+	if c.IdleTimeout != "" {
+		if d, err := time.ParseDuration(c.IdleTimeout); err != nil || d < 0 {
+			return invalid(configIdleTimeout, `must be a non-negative duration such as "5m"`)
+		}
+	}
+	if (c.TLSClientCertFile == "") != (c.TLSClientKeyFile == "") {
+		if c.TLSClientCertFile == "" {
+			return invalid(configTLSClientCertFile, "is required when %s is set", fieldName(configTLSClientKeyFile))
+		}
+		return invalid(configTLSClientKeyFile, "is required when %s is set", fieldName(configTLSClientCertFile))
+	}
+	if _, err := parseReadOnlyMode(c.ReadOnly); err != nil {
+		return invalid(configReadOnly, "%s", err)
+	}
+	return nil
+}
+
+// DataStoreFromConfig returns a configurable builder for a Redis-backed data store, like
+// DataStore, with the options from a Config. It returns an error if the Config is not valid.
+func DataStoreFromConfig(config Config) (*StoreBuilder[subsystems.PersistentDataStore], error) {
+	return applyConfig(DataStore(), config)
+}
+
+// BigSegmentStoreFromConfig returns a configurable builder for a Redis-backed Big Segment store,
+// like BigSegmentStore, with the options from a Config. It returns an error if the Config is not
+// valid. The ReadOnly option is ignored, since the Big Segment store never writes to Redis.
+func BigSegmentStoreFromConfig(config Config) (*StoreBuilder[subsystems.BigSegmentStore], error) {
+	return applyConfig(BigSegmentStore(), config)
+}
+
+func applyConfig[T any](b *StoreBuilder[T], config Config) (*StoreBuilder[T], error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.SocketPath != "" {
+		b.SocketPath(config.SocketPath)
+	} else {
+		b.URL(config.URL)
+	}
+	b.Prefix(config.Prefix)
+
+	var dialOptions []r.DialOption
+	if config.Username != "" {
+		dialOptions = append(dialOptions, r.DialUsername(config.Username))
+	}
+	if config.Password != "" {
+		dialOptions = append(dialOptions, r.DialPassword(config.Password))
+	}
+	if config.Database != 0 {
+		dialOptions = append(dialOptions, r.DialDatabase(config.Database))
+	}
+	if len(dialOptions) > 0 {
+		b.DialOptions(dialOptions...)
+	}
+
+	idleTimeout, _ := time.ParseDuration(config.IdleTimeout) // already validated; empty means zero
+	b.PoolLimits(config.MaxActive, config.MaxIdle, idleTimeout)
+	b.MinIdleConnections(config.MinIdle)
+
+	if config.TLSCACertFile != "" {
+		b.TLSCACertFile(config.TLSCACertFile)
+	}
+	if config.TLSClientCertFile != "" {
+		b.TLSClientCertFiles(config.TLSClientCertFile, config.TLSClientKeyFile)
+	}
+	if config.TLSServerName != "" {
+		b.TLSServerName(config.TLSServerName)
+	}
+
+	readOnly, _ := parseReadOnlyMode(config.ReadOnly) // already validated
+	b.ReadOnly(readOnly)
+	return b, nil
+}
+
+func parseReadOnlyMode(s string) (ReadOnlyMode, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return ReadWrite, nil
+	case "reject":
+		return ReadOnlyReject, nil
+	case "ignore":
+		return ReadOnlyIgnore, nil
+	default:
+		return ReadWrite, errors.New(`must be "reject" or "ignore"`)
+	}
+}
+
+// validateURL checks whether a Redis URL is one that the store can connect to. The error never
+// includes the URL itself, since it could contain a password.
+func validateURL(redisURL string) error {
+	parsed, err := url.Parse(redisURL)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // url.Error's message includes the URL
+		}
+		return fmt.Errorf("malformed URL: %s", err)
+	}
+	if !strings.Contains(redisURL, "://") {
+		return errors.New("URL must start with redis://, rediss://, or unix://")
+	}
+	switch parsed.Scheme {
+	case "redis", "rediss":
+		if parsed.Hostname() == "" {
+			return errors.New("URL has no host")
+		}
+		if db := strings.TrimPrefix(parsed.Path, "/"); db != "" {
+			if n, err := strconv.Atoi(db); err != nil || n < 0 {
+				return errors.New("URL path must be empty or a database number")
+			}
+		}
+	case unixSocketScheme:
+		if parsed.Path == "" {
+			return errors.New("socket path is missing from Unix socket URL")
+		}
+		if db := parsed.Query().Get("db"); db != "" {
+			if n, err := strconv.Atoi(db); err != nil || n < 0 {
+				return errors.New("db parameter must be a database number")
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported URL scheme %q; expected redis, rediss, or unix", parsed.Scheme)
+	}
+	return nil
+}
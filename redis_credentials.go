@@ -0,0 +1,142 @@
+package ldredis
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// CredentialsProvider is a function that returns the username and password to use for a new Redis
+// connection. See StoreBuilder.CredentialsProvider.
+//
+// If Redis is not using ACLs, username should be empty. If the function returns an error, the
+// connection attempt fails with that error.
+type CredentialsProvider func() (username string, password string, err error)
+
+var errStaleCredentials = errors.New("connection was authenticated with credentials that have since been rejected")
+
+// authErrorPrefixes are the Redis error replies that indicate that the credentials were rejected.
+var authErrorPrefixes = []string{
+	"WRONGPASS",
+	"NOAUTH",
+	"ERR invalid password",
+	"ERR invalid username-password pair",
+}
+
+func isAuthError(err error) bool {
+	var redisErr r.Error
+	if !errors.As(err, &redisErr) {
+		return false
+	}
+	for _, prefix := range authErrorPrefixes {
+		if strings.HasPrefix(string(redisErr), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialsDialer creates connections using credentials from a CredentialsProvider.
+//
+// Each connection remembers the credentials generation that was current when it was created. The
+// generation changes whenever Redis rejects the credentials; connections from older generations
+// then fail the pool's TestOnBorrow check, so idle connections are discarded instead of reused.
+type credentialsDialer struct {
+	url         string
+	dialOptions []r.DialOption
+	provider    CredentialsProvider
+	loggers     ldlog.Loggers
+	generation  uint64
+	dialURL     func(string, ...r.DialOption) (r.Conn, error)
+}
+
+type credentialsConn struct {
+	r.Conn
+	dialer     *credentialsDialer
+	generation uint64
+}
+
+func newCredentialsDialer(
+	builder builderOptions,
+	dialURL func(string, ...r.DialOption) (r.Conn, error),
+	loggers ldlog.Loggers,
+) *credentialsDialer {
+	return &credentialsDialer{
+		url:         builder.effectiveURL(),
+		dialOptions: builder.dialOptions,
+		provider:    builder.credentialsProvider,
+		loggers:     loggers,
+		dialURL:     dialURL,
+	}
+}
+
+// configurePool makes the pool use this dialer for new connections, and reject stale connections
+// in addition to its existing TestOnBorrow check.
+func (d *credentialsDialer) configurePool(pool *r.Pool) {
+	pool.Dial = d.dial
+	testOnBorrow := pool.TestOnBorrow
+	pool.TestOnBorrow = func(c r.Conn, t time.Time) error {
+		if cc, ok := c.(*credentialsConn); ok && cc.generation != atomic.LoadUint64(&d.generation) {
+			return errStaleCredentials
+		}
+		if testOnBorrow != nil {
+			return testOnBorrow(c, t)
+		}
+		return nil
+	}
+}
+
+func (d *credentialsDialer) dial() (r.Conn, error) {
+	c, err := d.dialWithCurrentCredentials()
+	if err != nil && isAuthError(err) {
+		d.loggers.Warnf("Redis rejected credentials (%s); discarding idle connections and retrying with new credentials", err)
+		d.invalidate()
+		c, err = d.dialWithCurrentCredentials()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &credentialsConn{Conn: c, dialer: d, generation: atomic.LoadUint64(&d.generation)}, nil
+}
+
+func (d *credentialsDialer) dialWithCurrentCredentials() (r.Conn, error) {
+	username, password, err := d.provider()
+	if err != nil {
+		return nil, err
+	}
+	options := make([]r.DialOption, 0, len(d.dialOptions)+2)
+	options = append(options, d.dialOptions...)
+	if username != "" {
+		options = append(options, r.DialUsername(username))
+	}
+	options = append(options, r.DialPassword(password))
+	return d.dialURL(d.url, options...)
+}
+
+func (d *credentialsDialer) invalidate() {
+	atomic.AddUint64(&d.generation, 1)
+}
+
+func (c *credentialsConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(commandName, args...)
+	c.checkError(err)
+	return reply, err
+}
+
+func (c *credentialsConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.checkError(err)
+	return reply, err
+}
+
+func (c *credentialsConn) checkError(err error) {
+	if err != nil && isAuthError(err) && atomic.LoadUint64(&c.dialer.generation) == c.generation {
+		c.dialer.loggers.Warnf("Redis rejected credentials (%s); discarding idle connections", err)
+		c.dialer.invalidate()
+	}
+}
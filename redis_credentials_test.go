@@ -0,0 +1,124 @@
+package ldredis
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errWrongPass = r.Error("WRONGPASS invalid username-password pair or user is disabled.")
+
+type credentialsTestParams struct {
+	mockLog       *ldlogtest.MockLog
+	faults        *ldredistest.FaultPool
+	providerCalls int
+	dialResults   []error
+	pool          *r.Pool
+}
+
+// newCredentialsTestParams creates a pool whose connections are to an in-memory server instead of
+// a real Redis. Each dial attempt consumes one element of dialResults, if any, as its result.
+func newCredentialsTestParams(provider CredentialsProvider) *credentialsTestParams {
+	p := &credentialsTestParams{
+		mockLog: ldlogtest.NewMockLog(),
+		faults:  ldredistest.NewFaultPool(ldredistest.NewServer().NewPool()),
+	}
+	countingProvider := func() (string, string, error) {
+		p.providerCalls++
+		return provider()
+	}
+	builder := builderOptions{url: DefaultURL, credentialsProvider: countingProvider}
+	p.pool = newPool(builderOptions{url: DefaultURL}, p.mockLog.Loggers)
+	dialer := newCredentialsDialer(builder, func(string, ...r.DialOption) (r.Conn, error) {
+		if len(p.dialResults) > 0 {
+			err := p.dialResults[0]
+			p.dialResults = p.dialResults[1:]
+			if err != nil {
+				return nil, err
+			}
+		}
+		return p.faults.Get(), nil
+	}, p.mockLog.Loggers)
+	dialer.configurePool(p.pool)
+	return p
+}
+
+func TestCredentialsProviderIsCalledForEachNewConnection(t *testing.T) {
+	p := newCredentialsTestParams(func() (string, string, error) { return "user", "pass", nil })
+	defer p.pool.Close() //nolint:errcheck
+
+	c1, c2 := p.pool.Get(), p.pool.Get()
+	require.NoError(t, c1.Err())
+	require.NoError(t, c2.Err())
+	assert.Equal(t, 2, p.providerCalls)
+	_ = c1.Close()
+	_ = c2.Close()
+
+	c3 := p.pool.Get() // reuses an idle connection
+	require.NoError(t, c3.Err())
+	assert.Equal(t, 2, p.providerCalls)
+	_ = c3.Close()
+}
+
+func TestCredentialsProviderErrorFailsConnection(t *testing.T) {
+	myErr := errors.New("secrets manager unavailable")
+	p := newCredentialsTestParams(func() (string, string, error) { return "", "", myErr })
+	defer p.pool.Close() //nolint:errcheck
+
+	c := p.pool.Get()
+	_, err := c.Do("PING")
+	assert.Equal(t, myErr, err)
+}
+
+func TestCredentialsProviderIsCalledAgainAfterAuthFailureOnDial(t *testing.T) {
+	p := newCredentialsTestParams(func() (string, string, error) { return "user", "pass", nil })
+	defer p.pool.Close() //nolint:errcheck
+	p.dialResults = []error{errWrongPass}
+
+	c := p.pool.Get()
+	_, err := c.Do("PING")
+	require.NoError(t, err)
+	_ = c.Close()
+	assert.Equal(t, 2, p.providerCalls)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Redis rejected credentials")
+}
+
+func TestIdleConnectionsAreDiscardedAfterAuthFailure(t *testing.T) {
+	p := newCredentialsTestParams(func() (string, string, error) { return "user", "pass", nil })
+	defer p.pool.Close() //nolint:errcheck
+	p.pool.IdleTimeout = time.Hour
+
+	c1, c2 := p.pool.Get(), p.pool.Get()
+	_, _ = c1.Do("PING")
+	_, _ = c2.Do("PING")
+	_ = c2.Close() // c2 is now idle
+	assert.Equal(t, 2, p.providerCalls)
+
+	p.faults.OnCommand("GET").Times(1).Fail(errWrongPass)
+	_, err := c1.Do("GET", "key")
+	assert.Equal(t, errWrongPass, err)
+	_ = c1.Close()
+
+	c3 := p.pool.Get() // both idle connections are stale, so this must be a new connection
+	_, err = c3.Do("PING")
+	require.NoError(t, err)
+	_ = c3.Close()
+	assert.Equal(t, 3, p.providerCalls)
+}
+
+func TestIsAuthError(t *testing.T) {
+	assert.True(t, isAuthError(errWrongPass))
+	assert.True(t, isAuthError(r.Error("NOAUTH Authentication required.")))
+	assert.True(t, isAuthError(r.Error("ERR invalid password")))
+	assert.False(t, isAuthError(r.Error("ERR unknown command")))
+	assert.False(t, isAuthError(errors.New("WRONGPASS")))
+}
@@ -0,0 +1,62 @@
+package ldredis
+
+import (
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// dataCopy is an in-memory copy of the latest data that was written to a store, so that it can be
+// written again later: for instance, to a region of a fan-out store that was unavailable, or by a
+// store that has just acquired the writer lease. It is not safe for concurrent use.
+type dataCopy struct {
+	kinds  []ldstoretypes.DataKind
+	items  map[string]map[string]ldstoretypes.SerializedItemDescriptor
+	inited bool
+}
+
+// init replaces the copy with the data from an Init.
+func (d *dataCopy) init(allData []ldstoretypes.SerializedCollection) {
+	d.kinds = make([]ldstoretypes.DataKind, 0, len(allData))
+	d.items = make(map[string]map[string]ldstoretypes.SerializedItemDescriptor, len(allData))
+	for _, coll := range allData {
+		d.kinds = append(d.kinds, coll.Kind)
+		items := make(map[string]ldstoretypes.SerializedItemDescriptor, len(coll.Items))
+		for _, item := range coll.Items {
+			items[item.Key] = item.Item
+		}
+		d.items[coll.Kind.GetName()] = items
+	}
+	d.inited = true
+}
+
+// upsert updates the copy with the item from an Upsert, unless it already has a newer version.
+func (d *dataCopy) upsert(kind ldstoretypes.DataKind, key string, newItem ldstoretypes.SerializedItemDescriptor) {
+	if d.items == nil {
+		d.items = make(map[string]map[string]ldstoretypes.SerializedItemDescriptor)
+	}
+	items := d.items[kind.GetName()]
+	if items == nil {
+		d.kinds = append(d.kinds, kind)
+		items = make(map[string]ldstoretypes.SerializedItemDescriptor)
+		d.items[kind.GetName()] = items
+	}
+	if oldItem, ok := items[key]; !ok || oldItem.Version < newItem.Version {
+		items[key] = newItem
+	}
+}
+
+// collections returns the copy in the form that Init takes.
+func (d *dataCopy) collections() []ldstoretypes.SerializedCollection {
+	allData := make([]ldstoretypes.SerializedCollection, 0, len(d.kinds))
+	for _, kind := range d.kinds {
+		items := d.items[kind.GetName()]
+		coll := ldstoretypes.SerializedCollection{
+			Kind:  kind,
+			Items: make([]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(items)),
+		}
+		for key, item := range items {
+			coll.Items = append(coll.Items, ldstoretypes.KeyedSerializedItemDescriptor{Key: key, Item: item})
+		}
+		allData = append(allData, coll)
+	}
+	return allData
+}
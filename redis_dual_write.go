@@ -0,0 +1,228 @@
+package ldredis
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// DualWriteDataStore returns a configurable builder for a data store that writes to two Redis data
+// stores at once. This is for migrating flag data from one Redis to another without downtime.
+//
+// Every Init and Upsert is applied to the primary store and then to the secondary store. Reads
+// come from the primary store; see [DualWriteStoreBuilder.ReadFallback] and
+// [DualWriteStoreBuilder.CompareReads] for ways to also use the secondary store. A typical
+// migration is to first make the old Redis the primary and the new one the secondary, and then,
+// once the new one has all of the data, to swap them:
+//
+//	config.DataStore = ldcomponents.PersistentDataStore(
+//		ldredis.DualWriteDataStore(
+//			ldredis.DataStore().URL(oldRedisURL),
+//			ldredis.DataStore().URL(newRedisURL),
+//		).CompareReads(true))
+//
+// The two stores are built with their own options, so for instance they can use different
+// prefixes. Options such as WriterLease that are specific to one store apply only to that store.
+func DualWriteDataStore(
+	primary, secondary *StoreBuilder[subsystems.PersistentDataStore],
+) *DualWriteStoreBuilder {
+	return &DualWriteStoreBuilder{primary: primary, secondary: secondary}
+}
+
+// DualWriteStoreBuilder is a builder for configuring a data store that writes to two Redis data
+// stores. See [DualWriteDataStore].
+type DualWriteStoreBuilder struct {
+	primary      *StoreBuilder[subsystems.PersistentDataStore]
+	secondary    *StoreBuilder[subsystems.PersistentDataStore]
+	readFallback bool
+	compareReads bool
+}
+
+// ReadFallback specifies whether to read from the secondary store when a read from the primary
+// store fails, or does not find an item. Finding an item only in the secondary store is logged
+// as a divergence. The default is false.
+func (b *DualWriteStoreBuilder) ReadFallback(fallback bool) *DualWriteStoreBuilder {
+	b.readFallback = fallback
+	return b
+}
+
+// CompareReads specifies whether every read from the primary store should also be made from the
+// secondary store, so that any difference between them can be logged as a divergence. The result
+// always comes from the primary store. This doubles the number of reads, so it is best used only
+// while verifying a migration. The default is false.
+func (b *DualWriteStoreBuilder) CompareReads(compare bool) *DualWriteStoreBuilder {
+	b.compareReads = compare
+	return b
+}
+
+// Build is called internally by the SDK.
+func (b *DualWriteStoreBuilder) Build(context subsystems.ClientContext) (subsystems.PersistentDataStore, error) {
+	primary, err := b.primary.Build(context)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := b.secondary.Build(context)
+	if err != nil {
+		_ = primary.Close()
+		return nil, fmt.Errorf("secondary data store: %w", err)
+	}
+	store := &dualWriteStoreImpl{
+		primary:      primary,
+		secondary:    secondary,
+		readFallback: b.readFallback,
+		compareReads: b.compareReads,
+		loggers:      context.GetLogging().Loggers,
+	}
+	store.loggers.SetPrefix("RedisDualWriteStore:")
+	return store, nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (b *DualWriteStoreBuilder) DescribeConfiguration() ldvalue.Value {
+	return ldvalue.ObjectBuild().
+		SetString("dataStoreType", "RedisDualWrite").
+		Set("primary", b.primary.DescribeConfiguration()).
+		Set("secondary", b.secondary.DescribeConfiguration()).
+		SetBool("readFallback", b.readFallback).
+		SetBool("compareReads", b.compareReads).
+		Build()
+}
+
+// Internal implementation of the PersistentDataStore interface that writes to two stores.
+type dualWriteStoreImpl struct {
+	primary      subsystems.PersistentDataStore
+	secondary    subsystems.PersistentDataStore
+	readFallback bool
+	compareReads bool
+	loggers      ldlog.Loggers
+}
+
+// Init initializes both stores. An error from either store is returned, so that the SDK retries
+// the Init once the store is available again.
+func (store *dualWriteStoreImpl) Init(allData []ldstoretypes.SerializedCollection) error {
+	primaryErr := store.primary.Init(allData)
+	secondaryErr := store.secondary.Init(allData)
+	return store.writeError("Init", primaryErr, secondaryErr)
+}
+
+func (store *dualWriteStoreImpl) Get(
+	kind ldstoretypes.DataKind,
+	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	item, err := store.primary.Get(kind, key)
+	if store.readFallback && (err != nil || item.SerializedItem == nil) {
+		secondaryItem, secondaryErr := store.secondary.Get(kind, key)
+		if err != nil {
+			store.loggers.Warnf("Reading from the secondary store because the primary store failed: %s", err)
+			return secondaryItem, secondaryErr
+		}
+		if secondaryErr == nil && secondaryItem.SerializedItem != nil {
+			store.loggers.Warnf("Divergence: %s %q was found only in the secondary store", kind.GetName(), key)
+			return secondaryItem, nil
+		}
+		return item, nil
+	}
+	if store.compareReads && err == nil {
+		secondaryItem, secondaryErr := store.secondary.Get(kind, key)
+		if secondaryErr == nil && !bytes.Equal(item.SerializedItem, secondaryItem.SerializedItem) {
+			store.loggers.Warnf("Divergence: %s %q is different in the primary and secondary stores",
+				kind.GetName(), key)
+		}
+	}
+	return item, err
+}
+
+func (store *dualWriteStoreImpl) GetAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	items, err := store.primary.GetAll(kind)
+	if err != nil {
+		if store.readFallback {
+			store.loggers.Warnf("Reading from the secondary store because the primary store failed: %s", err)
+			return store.secondary.GetAll(kind)
+		}
+		return nil, err
+	}
+	if store.compareReads {
+		if secondaryItems, err := store.secondary.GetAll(kind); err == nil {
+			if differences := countDifferences(items, secondaryItems); differences > 0 {
+				store.loggers.Warnf("Divergence: %d %s items are different in the primary and secondary stores",
+					differences, kind.GetName())
+			}
+		}
+	}
+	return items, nil
+}
+
+// Upsert updates both stores, and returns the result from the primary store.
+func (store *dualWriteStoreImpl) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	newItem ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	updated, primaryErr := store.primary.Upsert(kind, key, newItem)
+	secondaryUpdated, secondaryErr := store.secondary.Upsert(kind, key, newItem)
+	if primaryErr == nil && secondaryErr == nil && updated != secondaryUpdated {
+		changed := "secondary"
+		if updated {
+			changed = "primary"
+		}
+		store.loggers.Warnf("Divergence: Upsert of %s %q version %d changed only the %s store",
+			kind.GetName(), key, newItem.Version, changed)
+	}
+	return updated, store.writeError("Upsert", primaryErr, secondaryErr)
+}
+
+func (store *dualWriteStoreImpl) IsInitialized() bool {
+	return store.primary.IsInitialized() || (store.readFallback && store.secondary.IsInitialized())
+}
+
+// IsStoreAvailable returns true only if both stores are available, since writes that reach only
+// one of them make the stores diverge.
+func (store *dualWriteStoreImpl) IsStoreAvailable() bool {
+	return store.primary.IsStoreAvailable() && store.secondary.IsStoreAvailable()
+}
+
+func (store *dualWriteStoreImpl) Close() error {
+	primaryErr := store.primary.Close()
+	secondaryErr := store.secondary.Close()
+	if primaryErr != nil {
+		return primaryErr
+	}
+	return secondaryErr
+}
+
+// writeError returns the error to report for a write to both stores. If only the secondary store
+// failed, the error says so.
+func (store *dualWriteStoreImpl) writeError(operation string, primaryErr, secondaryErr error) error {
+	if primaryErr != nil {
+		if secondaryErr != nil {
+			store.loggers.Warnf("%s also failed for the secondary store: %s", operation, secondaryErr)
+		}
+		return primaryErr
+	}
+	if secondaryErr != nil {
+		return fmt.Errorf("secondary data store: %w", secondaryErr)
+	}
+	return nil
+}
+
+// countDifferences returns the number of keys whose items are not the same in both collections.
+func countDifferences(items1, items2 []ldstoretypes.KeyedSerializedItemDescriptor) int {
+	serialized := make(map[string][]byte, len(items1))
+	for _, item := range items1 {
+		serialized[item.Key] = item.Item.SerializedItem
+	}
+	differences := 0
+	for _, item := range items2 {
+		if data, ok := serialized[item.Key]; !ok || !bytes.Equal(data, item.Item.SerializedItem) {
+			differences++
+		}
+		delete(serialized, item.Key)
+	}
+	return differences + len(serialized)
+}
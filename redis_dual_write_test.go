@@ -0,0 +1,193 @@
+package ldredis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dualWriteTestParams struct {
+	store           subsystems.PersistentDataStore
+	primary         subsystems.PersistentDataStore // a separate store that reads and writes the primary Redis
+	secondary       subsystems.PersistentDataStore // a separate store that reads and writes the secondary Redis
+	primaryFaults   *ldredistest.FaultPool
+	secondaryFaults *ldredistest.FaultPool
+	mockLog         *ldlogtest.MockLog
+}
+
+func makeDualWriteTestStore(
+	t *testing.T,
+	configure func(*DualWriteStoreBuilder),
+) dualWriteTestParams {
+	primaryServer, secondaryServer := ldredistest.NewServer(), ldredistest.NewServer()
+	p := dualWriteTestParams{
+		primaryFaults:   ldredistest.NewFaultPool(primaryServer.NewPool()),
+		secondaryFaults: ldredistest.NewFaultPool(secondaryServer.NewPool()),
+		mockLog:         ldlogtest.NewMockLog(),
+	}
+	var context subsystems.BasicClientContext
+	context.Logging.Loggers = p.mockLog.Loggers
+	builder := DualWriteDataStore(
+		DataStore().PoolInterface(p.primaryFaults),
+		DataStore().PoolInterface(p.secondaryFaults).Prefix("new"),
+	)
+	if configure != nil {
+		configure(builder)
+	}
+	var err error
+	p.store, err = builder.Build(context)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.store.Close() })
+	p.primary, err = DataStore().PoolInterface(primaryServer.NewPool()).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.primary.Close() })
+	p.secondary, err = DataStore().PoolInterface(secondaryServer.NewPool()).Prefix("new").
+		Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.secondary.Close() })
+	return p
+}
+
+func TestDualWriteWritesToBothStores(t *testing.T) {
+	p := makeDualWriteTestStore(t, nil)
+	assert.False(t, p.store.IsInitialized())
+
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+	updated, err := p.store.Upsert(ldstoreimpl.Features(), "flag2", makeTestFlag("flag2", 1).Item)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	expected := map[string][]byte{
+		"flag1": makeTestFlag("flag1", 1).Item.SerializedItem,
+		"flag2": makeTestFlag("flag2", 1).Item.SerializedItem,
+	}
+	assert.Equal(t, expected, getAllFlags(t, p.primary))
+	assert.Equal(t, expected, getAllFlags(t, p.secondary))
+	assert.True(t, p.store.IsInitialized())
+	assert.True(t, p.store.IsStoreAvailable())
+	assert.Len(t, p.mockLog.GetOutput(ldlog.Warn), 0)
+}
+
+func TestDualWriteReturnsWriteErrors(t *testing.T) {
+	p := makeDualWriteTestStore(t, nil)
+	p.primaryFaults.OnCommand("EXEC").Times(1).Fail(nil)
+	assert.Equal(t, ldredistest.ErrInjectedFault, p.store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	assert.True(t, p.secondary.IsInitialized(), "the secondary store is still written")
+
+	p.secondaryFaults.OnCommand("EXEC").Fail(nil)
+	err := p.store.Init(makeTestFlagData(makeTestFlag("flag", 1)))
+	assert.Equal(t, "secondary data store: "+ldredistest.ErrInjectedFault.Error(), err.Error())
+	p.secondaryFaults.OnCommand("WATCH").Fail(nil)
+	_, err = p.store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	assert.Equal(t, "secondary data store: "+ldredistest.ErrInjectedFault.Error(), err.Error())
+
+	p.primaryFaults.OnCommand("EXISTS").Fail(nil)
+	assert.False(t, p.store.IsStoreAvailable())
+}
+
+func TestDualWriteLogsUpsertDivergence(t *testing.T) {
+	p := makeDualWriteTestStore(t, nil)
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	_, err := p.secondary.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 3).Item)
+	require.NoError(t, err)
+
+	updated, err := p.store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+	assert.True(t, updated)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		`Divergence: Upsert of features "flag" version 2 changed only the primary store`)
+}
+
+func TestDualWriteReadsFromPrimaryByDefault(t *testing.T) {
+	p := makeDualWriteTestStore(t, nil)
+	require.NoError(t, p.secondary.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	assert.False(t, p.store.IsInitialized())
+	item, err := p.store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Nil(t, item.SerializedItem)
+
+	p.primaryFaults.OnCommand("HGETALL").Times(1).Fail(nil)
+	_, err = p.store.GetAll(ldstoreimpl.Features())
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+}
+
+func TestDualWriteReadFallback(t *testing.T) {
+	p := makeDualWriteTestStore(t, func(b *DualWriteStoreBuilder) { b.ReadFallback(true) })
+	require.NoError(t, p.secondary.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	assert.True(t, p.store.IsInitialized())
+
+	item, err := p.store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 1).Item.SerializedItem, item.SerializedItem)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn, `Divergence: features "flag" was found only in the secondary store`)
+
+	item, err = p.store.Get(ldstoreimpl.Features(), "other")
+	require.NoError(t, err)
+	assert.Nil(t, item.SerializedItem)
+
+	p.primaryFaults.OnCommand("HGETALL").Times(1).Fail(nil)
+	items, err := p.store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		"Reading from the secondary store because the primary store failed: "+ldredistest.ErrInjectedFault.Error())
+}
+
+func TestDualWriteCompareReads(t *testing.T) {
+	p := makeDualWriteTestStore(t, func(b *DualWriteStoreBuilder) { b.CompareReads(true) })
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag1", 1), makeTestFlag("flag2", 1))))
+
+	item, err := p.store.Get(ldstoreimpl.Features(), "flag1")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag1", 1).Item.SerializedItem, item.SerializedItem)
+	_, err = p.store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, p.mockLog.GetOutput(ldlog.Warn), 0)
+
+	_, err = p.secondary.Upsert(ldstoreimpl.Features(), "flag1", makeTestFlag("flag1", 2).Item)
+	require.NoError(t, err)
+	_, err = p.secondary.Upsert(ldstoreimpl.Features(), "flag3", makeTestFlag("flag3", 1).Item)
+	require.NoError(t, err)
+
+	item, err = p.store.Get(ldstoreimpl.Features(), "flag1")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag1", 1).Item.SerializedItem, item.SerializedItem)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		`Divergence: features "flag1" is different in the primary and secondary stores`)
+
+	items, err := p.store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		"Divergence: 2 features items are different in the primary and secondary stores")
+}
+
+func TestDualWriteBuildFailsIfEitherStoreFails(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	faults.OnCommand("PING").Fail(nil)
+	_, err := DualWriteDataStore(
+		DataStore().PoolInterface(ldredistest.NewServer().NewPool()),
+		DataStore().PoolInterface(faults).StartupCheck(time.Second),
+	).Build(subsystems.BasicClientContext{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "secondary data store:")
+}
+
+func TestDualWriteDescribeConfiguration(t *testing.T) {
+	description := DualWriteDataStore(DataStore(), DataStore().Prefix("new")).ReadFallback(true).
+		DescribeConfiguration()
+	assert.Equal(t, "RedisDualWrite", description.GetByKey("dataStoreType").StringValue())
+	assert.True(t, description.GetByKey("primary").GetByKey("usingDefaultPrefix").BoolValue())
+	assert.False(t, description.GetByKey("secondary").GetByKey("usingDefaultPrefix").BoolValue())
+	assert.True(t, description.GetByKey("readFallback").BoolValue())
+	assert.False(t, description.GetByKey("compareReads").BoolValue())
+}
@@ -0,0 +1,91 @@
+package ldredis
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/testhelpers/storetest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests run the same test suites as TestRedisDataStore and TestBigSegmentStore, but against
+// the in-memory server from the ldredistest package rather than a real Redis instance.
+
+func TestRedisDataStoreWithFakeServer(t *testing.T) {
+	server := ldredistest.NewServer()
+	storetest.NewPersistentDataStoreTestSuite(
+		func(prefix string) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+			return DataStore().Prefix(prefix).PoolInterface(server.NewPool())
+		},
+		makeFakeServerClearFn(server),
+	).
+		ErrorStoreFactory(makeFakeServerFailedStore(server), verifyFakeServerFailedStoreError).
+		ConcurrentModificationHook(setConcurrentModificationHook).
+		Run(t)
+}
+
+func makeFakeServerFailedStore(server *ldredistest.Server) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+	faults := ldredistest.NewFaultPool(server.NewPool())
+	faults.OnCommand("*").Fail(nil)
+	return DataStore().PoolInterface(faults)
+}
+
+func verifyFakeServerFailedStoreError(t assert.TestingT, err error) {
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+}
+
+func TestBigSegmentStoreWithFakeServer(t *testing.T) {
+	server := ldredistest.NewServer()
+	client := server.NewPool().Get()
+	defer client.Close()
+
+	setTestMetadata := func(prefix string, metadata subsystems.BigSegmentStoreMetadata) error {
+		if prefix == "" {
+			prefix = DefaultPrefix
+		}
+		_, err := client.Do("SET", bigSegmentsSyncTimeKey(prefix), fmt.Sprintf("%d", metadata.LastUpToDate))
+		return err
+	}
+
+	setTestSegments := func(prefix string, contextHashKey string, included []string, excluded []string) error {
+		if prefix == "" {
+			prefix = DefaultPrefix
+		}
+		for _, inc := range included {
+			_, err := client.Do("SADD", bigSegmentsIncludeKey(prefix, contextHashKey), inc)
+			if err != nil {
+				return err
+			}
+		}
+		for _, exc := range excluded {
+			_, err := client.Do("SADD", bigSegmentsExcludeKey(prefix, contextHashKey), exc)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	storetest.NewBigSegmentStoreTestSuite(
+		func(prefix string) subsystems.ComponentConfigurer[subsystems.BigSegmentStore] {
+			return BigSegmentStore().Prefix(prefix).PoolInterface(server.NewPool())
+		},
+		makeFakeServerClearFn(server),
+		setTestMetadata,
+		setTestSegments,
+	).Run(t)
+}
+
+func makeFakeServerClearFn(server *ldredistest.Server) func(string) error {
+	return func(prefix string) error {
+		if prefix == "" {
+			prefix = DefaultPrefix
+		}
+		client := server.NewPool().Get()
+		defer client.Close()
+		return clearTestDataWithConn(client, prefix)
+	}
+}
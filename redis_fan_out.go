@@ -0,0 +1,457 @@
+package ldredis
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// DefaultRegionRecoveryInterval is the default value for FanOutStoreBuilder.RecoveryInterval.
+const DefaultRegionRecoveryInterval = 10 * time.Second
+
+// FanOutDataStore returns a configurable builder for a data store that writes to a Redis data store
+// in each of several regions. This allows one SDK instance to populate all of them.
+//
+// Add each region with [FanOutStoreBuilder.Region]:
+//
+//	config.DataStore = ldcomponents.PersistentDataStore(
+//		ldredis.FanOutDataStore().
+//			Region("us-east", ldredis.DataStore().URL(usEastRedisURL)).
+//			Region("eu-west", ldredis.DataStore().URL(euWestRedisURL)))
+//
+// Every Init and Upsert is applied to all regions in parallel. If a write to a region fails, the
+// region is marked as unavailable and is not written to until it can be reached again; it is then
+// initialized with the current data, which the store keeps in memory for this purpose. A write only
+// returns an error if it failed for every available region.
+//
+// Reads come from the first region, in the order they were added, that is available. The state of
+// each region can be found with [RegionStatusReader].
+func FanOutDataStore() *FanOutStoreBuilder {
+	return &FanOutStoreBuilder{recoveryInterval: DefaultRegionRecoveryInterval}
+}
+
+// FanOutStoreBuilder is a builder for configuring a data store that writes to several Redis data
+// stores. See [FanOutDataStore].
+type FanOutStoreBuilder struct {
+	regions          []fanOutRegionConfig
+	recoveryInterval time.Duration
+}
+
+type fanOutRegionConfig struct {
+	name    string
+	builder *StoreBuilder[subsystems.PersistentDataStore]
+}
+
+// Region adds a region with the specified name, whose data store is configured by the specified
+// builder. The name is used only in log messages and in RegionStatus.
+func (b *FanOutStoreBuilder) Region(
+	name string,
+	builder *StoreBuilder[subsystems.PersistentDataStore],
+) *FanOutStoreBuilder {
+	b.regions = append(b.regions, fanOutRegionConfig{name: name, builder: builder})
+	return b
+}
+
+// RecoveryInterval specifies how often to check whether an unavailable region can be reached
+// again. The default is DefaultRegionRecoveryInterval.
+func (b *FanOutStoreBuilder) RecoveryInterval(interval time.Duration) *FanOutStoreBuilder {
+	if interval <= 0 {
+		interval = DefaultRegionRecoveryInterval
+	}
+	b.recoveryInterval = interval
+	return b
+}
+
+// Build is called internally by the SDK.
+func (b *FanOutStoreBuilder) Build(context subsystems.ClientContext) (subsystems.PersistentDataStore, error) {
+	if len(b.regions) == 0 {
+		return nil, errors.New("fan-out data store has no regions")
+	}
+	store := &fanOutStoreImpl{
+		closer:  make(chan struct{}),
+		done:    make(chan struct{}),
+		loggers: context.GetLogging().Loggers,
+	}
+	store.loggers.SetPrefix("RedisFanOutStore:")
+	for _, config := range b.regions {
+		for _, region := range store.regions {
+			if region.name == config.name {
+				_ = store.closeRegions()
+				return nil, fmt.Errorf("fan-out data store has more than one region named %q", config.name)
+			}
+		}
+		regionStore, err := config.builder.Build(context)
+		if err != nil {
+			_ = store.closeRegions()
+			return nil, fmt.Errorf("region %q: %w", config.name, err)
+		}
+		store.regions = append(store.regions, &fanOutRegion{name: config.name, store: regionStore, available: true})
+	}
+	go store.runRecovery(b.recoveryInterval)
+	return store, nil
+}
+
+// DescribeConfiguration is used internally by the SDK to inspect the configuration.
+func (b *FanOutStoreBuilder) DescribeConfiguration() ldvalue.Value {
+	regions := ldvalue.ObjectBuild()
+	for _, region := range b.regions {
+		regions.Set(region.name, region.builder.DescribeConfiguration())
+	}
+	return ldvalue.ObjectBuild().
+		SetString("dataStoreType", "RedisFanOut").
+		Set("regions", regions.Build()).
+		SetFloat64("recoveryIntervalMillis", float64(b.recoveryInterval.Milliseconds())).
+		Build()
+}
+
+// RegionStatus describes the state of one region of a fan-out data store. See RegionStatusReader.
+type RegionStatus struct {
+	// Name is the name that was given to FanOutStoreBuilder.Region.
+	Name string
+	// Available is false if the latest write to the region failed, and the region has not yet been
+	// initialized again.
+	Available bool
+	// LastError is the error from the latest write that failed, or nil if no write has failed.
+	LastError error
+}
+
+// RegionStatusReader is implemented by the data store that is created by FanOutDataStore. It
+// reports the state of each region:
+//
+//	statuses := store.(ldredis.RegionStatusReader).GetRegionStatuses()
+type RegionStatusReader interface {
+	subsystems.PersistentDataStore
+
+	// GetRegionStatuses returns the state of each region, in the order the regions were added.
+	GetRegionStatuses() []RegionStatus
+}
+
+// Internal implementation of the PersistentDataStore interface that writes to several stores.
+//
+// The store keeps a copy of the latest data, so that a region can be initialized again after it
+// has been unavailable. While a region is being initialized, the updates that it misses are
+// recorded, and are applied to it afterward unless a newer Init replaces them.
+type fanOutStoreImpl struct {
+	regions   []*fanOutRegion
+	lock      sync.Mutex
+	data      dataCopy
+	closer    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	loggers   ldlog.Loggers
+}
+
+// The fields of fanOutRegion other than name and store are guarded by fanOutStoreImpl.lock.
+type fanOutRegion struct {
+	name       string
+	store      subsystems.PersistentDataStore
+	available  bool
+	lastError  error
+	recovering bool
+	missed     dataCopy // the updates that were not written to a recovering region
+	missedInit bool     // true if an Init was not written to a recovering region
+	replaying  bool     // true while the missed updates are written; an Init sets it to false
+
+	// replayLock is held while a missed update is written, and while an Init is written, so that an
+	// update that an Init has replaced cannot be written after the Init.
+	replayLock sync.Mutex
+}
+
+func (store *fanOutStoreImpl) Init(allData []ldstoretypes.SerializedCollection) error {
+	store.lock.Lock()
+	store.data.init(allData)
+	for _, region := range store.regions {
+		if region.recovering {
+			region.missed, region.missedInit = dataCopy{}, true
+		}
+		region.replaying = false
+	}
+	regions := store.availableRegions()
+	store.lock.Unlock()
+
+	_, err := store.writeToRegions(regions, "Init", func(region *fanOutRegion) (bool, error) {
+		region.replayLock.Lock()
+		defer region.replayLock.Unlock()
+		return true, region.store.Init(allData)
+	})
+	return err
+}
+
+func (store *fanOutStoreImpl) Get(
+	kind ldstoretypes.DataKind,
+	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	var err error
+	for _, region := range store.readRegions() {
+		var item ldstoretypes.SerializedItemDescriptor
+		if item, err = region.store.Get(kind, key); err == nil {
+			return item, nil
+		}
+	}
+	return ldstoretypes.SerializedItemDescriptor{}.NotFound(), err
+}
+
+func (store *fanOutStoreImpl) GetAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	var err error
+	for _, region := range store.readRegions() {
+		var items []ldstoretypes.KeyedSerializedItemDescriptor
+		if items, err = region.store.GetAll(kind); err == nil {
+			return items, nil
+		}
+	}
+	return nil, err
+}
+
+// Upsert updates every available region, and returns true if any of them was updated.
+func (store *fanOutStoreImpl) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	newItem ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	store.lock.Lock()
+	store.data.upsert(kind, key, newItem)
+	for _, region := range store.regions {
+		if region.recovering {
+			region.missed.upsert(kind, key, newItem)
+		}
+	}
+	regions := store.availableRegions()
+	store.lock.Unlock()
+
+	return store.writeToRegions(regions, "Upsert", func(region *fanOutRegion) (bool, error) {
+		return region.store.Upsert(kind, key, newItem)
+	})
+}
+
+func (store *fanOutStoreImpl) IsInitialized() bool {
+	for _, region := range store.readRegions() {
+		if region.store.IsInitialized() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStoreAvailable returns true if any region is available.
+func (store *fanOutStoreImpl) IsStoreAvailable() bool {
+	for _, region := range store.readRegions() {
+		if region.store.IsStoreAvailable() {
+			return true
+		}
+	}
+	return false
+}
+
+func (store *fanOutStoreImpl) Close() error {
+	var err error
+	store.closeOnce.Do(func() {
+		close(store.closer)
+		<-store.done
+		err = store.closeRegions()
+	})
+	return err
+}
+
+// GetRegionStatuses returns the state of each region. See RegionStatusReader.
+func (store *fanOutStoreImpl) GetRegionStatuses() []RegionStatus {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	statuses := make([]RegionStatus, 0, len(store.regions))
+	for _, region := range store.regions {
+		statuses = append(statuses, RegionStatus{
+			Name:      region.name,
+			Available: region.available,
+			LastError: region.lastError,
+		})
+	}
+	return statuses
+}
+
+// writeToRegions calls a write function for each of the specified regions in parallel. It returns
+// true if the function returned true for any region, and an error only if every region failed.
+func (store *fanOutStoreImpl) writeToRegions(
+	regions []*fanOutRegion,
+	operation string,
+	write func(*fanOutRegion) (bool, error),
+) (bool, error) {
+	if len(regions) == 0 {
+		return false, errors.New("no region is available")
+	}
+	results := make([]bool, len(regions))
+	errs := make([]error, len(regions))
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region *fanOutRegion) {
+			defer wg.Done()
+			results[i], errs[i] = write(region)
+		}(i, region)
+	}
+	wg.Wait()
+
+	var result bool
+	var lastErr error
+	succeeded := false
+	for i, region := range regions {
+		if errs[i] != nil {
+			store.markUnavailable(region, operation, errs[i])
+			lastErr = errs[i]
+			continue
+		}
+		succeeded = true
+		result = result || results[i]
+	}
+	if !succeeded {
+		return false, lastErr
+	}
+	return result, nil
+}
+
+func (store *fanOutStoreImpl) markUnavailable(region *fanOutRegion, operation string, err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	region.lastError = err
+	if region.available {
+		region.available = false
+		store.loggers.Warnf("%s failed for region %q, which will be initialized again when it is available: %s",
+			operation, region.name, err)
+	}
+}
+
+// availableRegions returns the regions that should be written to. The caller must hold the lock.
+func (store *fanOutStoreImpl) availableRegions() []*fanOutRegion {
+	regions := make([]*fanOutRegion, 0, len(store.regions))
+	for _, region := range store.regions {
+		if region.available {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// readRegions returns the available regions, followed by the unavailable ones in case a region
+// can be read from even though a write to it failed.
+func (store *fanOutStoreImpl) readRegions() []*fanOutRegion {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	regions := store.availableRegions()
+	for _, region := range store.regions {
+		if !region.available {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+func (store *fanOutStoreImpl) runRecovery(interval time.Duration) {
+	defer close(store.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-store.closer:
+			return
+		case <-ticker.C:
+			store.recoverRegions()
+		}
+	}
+}
+
+// recoverRegions initializes each unavailable region that can be reached again with the current
+// data, and marks it as available.
+func (store *fanOutStoreImpl) recoverRegions() {
+	store.lock.Lock()
+	var unavailable []*fanOutRegion
+	for _, region := range store.regions {
+		if !region.available {
+			unavailable = append(unavailable, region)
+		}
+	}
+	store.lock.Unlock()
+
+	for _, region := range unavailable {
+		if region.store.IsStoreAvailable() {
+			store.recoverRegion(region)
+		}
+	}
+}
+
+// recoverRegion initializes a region with the current data and marks it as available, and then
+// writes the updates that were made during the Init. Since an Upsert never replaces a newer version
+// of an item, those updates can safely be written after the region starts receiving new ones, so
+// that steady updates cannot keep the region from becoming available. An Init that was made during
+// the region's Init causes it to be initialized again, and an Init that is made while the updates
+// are written stops them, since it replaces them.
+func (store *fanOutStoreImpl) recoverRegion(region *fanOutRegion) {
+	store.lock.Lock()
+	region.recovering, region.missed, region.missedInit = true, dataCopy{}, store.data.inited
+	for region.missedInit {
+		allData := store.data.collections()
+		region.missed, region.missedInit = dataCopy{}, false
+		store.lock.Unlock()
+		if err := region.store.Init(allData); err != nil {
+			store.lock.Lock()
+			region.lastError, region.recovering, region.missed = err, false, dataCopy{}
+			store.lock.Unlock()
+			return
+		}
+		store.lock.Lock()
+	}
+	missed := region.missed.collections()
+	region.available, region.recovering, region.missed = true, false, dataCopy{}
+	region.replaying = true
+	store.lock.Unlock()
+	store.loggers.Infof("Region %q is available again and has been initialized", region.name)
+
+	for _, coll := range missed {
+		for _, item := range coll.Items {
+			if !store.replayUpdate(region, coll.Kind, item) {
+				return
+			}
+		}
+	}
+	store.lock.Lock()
+	region.replaying = false
+	store.lock.Unlock()
+}
+
+// replayUpdate writes an update that a region missed while it was being initialized. It returns
+// false if the remaining updates should not be written, because the write failed or because an
+// Init has been made since the region became available.
+func (store *fanOutStoreImpl) replayUpdate(
+	region *fanOutRegion,
+	kind ldstoretypes.DataKind,
+	item ldstoretypes.KeyedSerializedItemDescriptor,
+) bool {
+	region.replayLock.Lock()
+	defer region.replayLock.Unlock()
+	store.lock.Lock()
+	replaying := region.replaying
+	store.lock.Unlock()
+	if !replaying {
+		return false
+	}
+	if _, err := region.store.Upsert(kind, item.Key, item.Item); err != nil {
+		store.markUnavailable(region, "Upsert", err)
+		return false
+	}
+	return true
+}
+
+func (store *fanOutStoreImpl) closeRegions() error {
+	var err error
+	for _, region := range store.regions {
+		if closeErr := region.store.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
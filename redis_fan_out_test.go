@@ -0,0 +1,219 @@
+package ldredis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fanOutTestParams struct {
+	store   RegionStatusReader
+	regions []subsystems.PersistentDataStore // separate stores that read each region's Redis
+	faults  []*ldredistest.FaultPool
+	mockLog *ldlogtest.MockLog
+}
+
+func makeFanOutTestStore(t *testing.T, names ...string) fanOutTestParams {
+	p := fanOutTestParams{mockLog: ldlogtest.NewMockLog()}
+	builder := FanOutDataStore().RecoveryInterval(10 * time.Millisecond)
+	for _, name := range names {
+		server := ldredistest.NewServer()
+		faults := ldredistest.NewFaultPool(server.NewPool())
+		builder.Region(name, DataStore().PoolInterface(faults))
+		region, err := DataStore().PoolInterface(server.NewPool()).Build(subsystems.BasicClientContext{})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = region.Close() })
+		p.regions = append(p.regions, region)
+		p.faults = append(p.faults, faults)
+	}
+	var context subsystems.BasicClientContext
+	context.Logging.Loggers = p.mockLog.Loggers
+	store, err := builder.Build(context)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	p.store = store.(RegionStatusReader)
+	return p
+}
+
+func TestFanOutWritesToAllRegions(t *testing.T) {
+	p := makeFanOutTestStore(t, "a", "b")
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+	updated, err := p.store.Upsert(ldstoreimpl.Features(), "flag2", makeTestFlag("flag2", 1).Item)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	expected := map[string][]byte{
+		"flag1": makeTestFlag("flag1", 1).Item.SerializedItem,
+		"flag2": makeTestFlag("flag2", 1).Item.SerializedItem,
+	}
+	for _, region := range p.regions {
+		assert.Equal(t, expected, getAllFlags(t, region))
+	}
+	assert.True(t, p.store.IsInitialized())
+	assert.True(t, p.store.IsStoreAvailable())
+	assert.Equal(t, []RegionStatus{{Name: "a", Available: true}, {Name: "b", Available: true}},
+		p.store.GetRegionStatuses())
+}
+
+func TestFanOutRegionIsInitializedAgainWhenItRecovers(t *testing.T) {
+	p := makeFanOutTestStore(t, "a", "b")
+	p.faults[1].OnCommand("EXEC").Fail(nil)
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+	assert.Equal(t, []RegionStatus{
+		{Name: "a", Available: true},
+		{Name: "b", Available: false, LastError: ldredistest.ErrInjectedFault},
+	}, p.store.GetRegionStatuses())
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		`Init failed for region "b", which will be initialized again when it is available: `+
+			ldredistest.ErrInjectedFault.Error())
+
+	_, err := p.store.Upsert(ldstoreimpl.Features(), "flag1", makeTestFlag("flag1", 2).Item)
+	require.NoError(t, err)
+	_, err = p.store.Upsert(ldstoreimpl.Features(), "flag2", makeTestFlag("flag2", 1).Item)
+	require.NoError(t, err)
+	assert.False(t, p.regions[1].IsInitialized())
+	assert.Len(t, p.mockLog.GetOutput(ldlog.Warn), 1)
+
+	p.faults[1].Clear()
+	require.Eventually(t, func() bool { return p.store.GetRegionStatuses()[1].Available }, time.Second,
+		5*time.Millisecond)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Info, `Region "b" is available again and has been initialized`)
+	assert.Equal(t, getAllFlags(t, p.regions[0]), getAllFlags(t, p.regions[1]))
+	assert.Equal(t, makeTestFlag("flag1", 2).Item.SerializedItem, getAllFlags(t, p.regions[1])["flag1"])
+}
+
+func TestFanOutRegionRecoversDuringSteadyUpdates(t *testing.T) {
+	p := makeFanOutTestStore(t, "a", "b")
+	p.faults[1].OnCommand("EXEC").Fail(nil)
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	var lastVersion int
+	go func() {
+		defer close(stopped)
+		for version := 2; ; version++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_, err := p.store.Upsert(ldstoreimpl.Features(), "flag1", makeTestFlag("flag1", version).Item)
+			assert.NoError(t, err)
+			lastVersion = version
+			time.Sleep(2 * time.Millisecond)
+		}
+	}()
+
+	p.faults[1].Clear()
+	p.faults[1].OnCommand("EXEC").Delay(20 * time.Millisecond) // every write to region b takes longer than an update
+	assert.Eventually(t, func() bool { return p.store.GetRegionStatuses()[1].Available }, time.Second,
+		5*time.Millisecond)
+	close(stop)
+	<-stopped
+
+	expected := makeTestFlag("flag1", lastVersion).Item.SerializedItem
+	assert.Eventually(t, func() bool { return string(getAllFlags(t, p.regions[1])["flag1"]) == string(expected) },
+		time.Second, 5*time.Millisecond)
+	assert.Equal(t, expected, getAllFlags(t, p.regions[0])["flag1"])
+}
+
+func TestFanOutInitStopsReplayOfMissedUpdates(t *testing.T) {
+	p := makeFanOutTestStore(t, "a", "b")
+	impl := p.store.(*fanOutStoreImpl)
+	p.faults[1].OnCommand("EXEC").Fail(nil)
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+
+	// flag2 is added while region b is being initialized, so it is written to the region afterward.
+	// That write is slowed down so that an Init which removes flag2 is made during it.
+	p.faults[1].Clear()
+	p.faults[1].OnCommand("EXEC").Times(1).Delay(50 * time.Millisecond)
+	p.faults[1].OnCommand("HGET").Delay(50 * time.Millisecond)
+	regionState := func(get func(*fanOutRegion) bool) bool {
+		impl.lock.Lock()
+		defer impl.lock.Unlock()
+		return get(impl.regions[1])
+	}
+	require.Eventually(t, func() bool { return regionState(func(r *fanOutRegion) bool { return r.recovering }) },
+		time.Second, time.Millisecond)
+	_, err := p.store.Upsert(ldstoreimpl.Features(), "flag2", makeTestFlag("flag2", 1).Item)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return regionState(func(r *fanOutRegion) bool { return r.available }) },
+		time.Second, time.Millisecond)
+
+	require.NoError(t, p.store.Init(makeTestFlagData(makeTestFlag("flag1", 2))))
+	require.NoError(t, p.store.Close()) // waits for the recovery to finish
+	assert.Equal(t, map[string][]byte{"flag1": makeTestFlag("flag1", 2).Item.SerializedItem},
+		getAllFlags(t, p.regions[1]))
+}
+
+func TestFanOutReturnsErrorIfAllRegionsFail(t *testing.T) {
+	p := makeFanOutTestStore(t, "a", "b")
+	p.faults[0].OnCommand("EXEC").Fail(nil)
+	p.faults[1].OnCommand("EXEC").Fail(nil)
+	assert.Equal(t, ldredistest.ErrInjectedFault, p.store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	_, err := p.store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	assert.EqualError(t, err, "no region is available")
+	assert.True(t, p.store.IsStoreAvailable(), "the regions can still be reached")
+}
+
+func TestFanOutReadsFromFirstRegionThatSucceeds(t *testing.T) {
+	p := makeFanOutTestStore(t, "a", "b")
+	require.NoError(t, p.regions[1].Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	item, err := p.store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Nil(t, item.SerializedItem, "region a has no data")
+
+	p.faults[0].OnCommand("HGET").Fail(nil)
+	p.faults[0].OnCommand("HGETALL").Fail(nil)
+	item, err = p.store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 1).Item.SerializedItem, item.SerializedItem)
+	items, err := p.store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	p.faults[1].OnCommand("HGET").Fail(nil)
+	_, err = p.store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+}
+
+func TestFanOutBuildErrors(t *testing.T) {
+	_, err := FanOutDataStore().Build(subsystems.BasicClientContext{})
+	assert.EqualError(t, err, "fan-out data store has no regions")
+
+	server := ldredistest.NewServer()
+	_, err = FanOutDataStore().
+		Region("a", DataStore().PoolInterface(server.NewPool())).
+		Region("a", DataStore().PoolInterface(server.NewPool())).
+		Build(subsystems.BasicClientContext{})
+	assert.EqualError(t, err, `fan-out data store has more than one region named "a"`)
+
+	faults := ldredistest.NewFaultPool(server.NewPool())
+	faults.OnCommand("PING").Fail(nil)
+	_, err = FanOutDataStore().
+		Region("a", DataStore().PoolInterface(server.NewPool())).
+		Region("b", DataStore().PoolInterface(faults).StartupCheck(time.Second)).
+		Build(subsystems.BasicClientContext{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `region "b":`)
+}
+
+func TestFanOutDescribeConfiguration(t *testing.T) {
+	description := FanOutDataStore().Region("a", DataStore()).Region("b", DataStore().Prefix("b")).
+		DescribeConfiguration()
+	assert.Equal(t, "RedisFanOut", description.GetByKey("dataStoreType").StringValue())
+	assert.ElementsMatch(t, []string{"a", "b"}, description.GetByKey("regions").Keys(nil))
+	assert.Equal(t, float64(DefaultRegionRecoveryInterval.Milliseconds()),
+		description.GetByKey("recoveryIntervalMillis").Float64Value())
+}
@@ -0,0 +1,118 @@
+package ldredis
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// These tests use the fault injection pool from ldredistest to verify how the stores behave when
+// Redis operations fail in ways that are hard to reproduce with a real Redis instance.
+
+func makeTestFlag(key string, version int) ldstoretypes.KeyedSerializedItemDescriptor {
+	flag := ldbuilders.NewFlagBuilder(key).Version(version).Build()
+	return ldstoretypes.KeyedSerializedItemDescriptor{
+		Key: key,
+		Item: ldstoretypes.SerializedItemDescriptor{
+			Version:        version,
+			SerializedItem: ldstoreimpl.Features().Serialize(ldstoretypes.ItemDescriptor{Version: version, Item: &flag}),
+		},
+	}
+}
+
+func makeTestFlagData(flags ...ldstoretypes.KeyedSerializedItemDescriptor) []ldstoretypes.SerializedCollection {
+	return []ldstoretypes.SerializedCollection{
+		{Kind: ldstoreimpl.Features(), Items: flags},
+		{Kind: ldstoreimpl.Segments()},
+	}
+}
+
+// testUserAgent is the User-Agent header of the SDK in the context that buildTestStore uses.
+const testUserAgent = "GoClient/7.0.0 LDRelay/8.0.0"
+
+// buildTestStore builds a data store, and closes it when the test ends. If no pool was specified,
+// the store uses a new fake Redis server. Messages are logged to the returned MockLog.
+func buildTestStore(
+	t *testing.T,
+	builder *StoreBuilder[subsystems.PersistentDataStore],
+) (*redisDataStoreImpl, *ldlogtest.MockLog) {
+	if builder.builderOptions.pool == nil {
+		builder.PoolInterface(ldredistest.NewServer().NewPool())
+	}
+	mockLog := ldlogtest.NewMockLog()
+	var context subsystems.BasicClientContext
+	context.Logging.Loggers = mockLog.Loggers
+	context.HTTP.DefaultHeaders = http.Header{"User-Agent": []string{testUserAgent}}
+	store, err := builder.Build(context)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store.(*redisDataStoreImpl), mockLog
+}
+
+func TestUpsertRetriesAfterTransactionIsAborted(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	faults.OnCommand("EXEC").Times(2).AbortExec()
+	updated, err := store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	item, err := store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 2).Item.SerializedItem, item.SerializedItem)
+}
+
+func TestInitDroppedMidTransactionLeavesPreviousData(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+
+	faults.OnCommand("EXEC").Times(1).DropConnection()
+	err := store.Init(makeTestFlagData(makeTestFlag("flag2", 1)))
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "flag1", items[0].Key)
+}
+
+func TestReadErrorsAreReturnedAndDoNotAffectLaterReads(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	faults.OnGet().Times(1).Fail(nil)
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+
+	faults.OnCommand("HGETALL").Times(1).Fail(nil)
+	_, err = store.GetAll(ldstoreimpl.Features())
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+
+	item, err := store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 1).Item.SerializedItem, item.SerializedItem)
+}
+
+func TestBigSegmentStoreReturnsInjectedErrors(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	faults.OnCommand("SMEMBERS").Fail(nil)
+	store, err := BigSegmentStore().PoolInterface(faults).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	_, err = store.GetMembership("abc")
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+}
@@ -0,0 +1,162 @@
+package ldredis
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+)
+
+const updatedKey = "$updated"
+
+// stalenessCheckInterval is the longest time between two checks of the age of the data, if a
+// staleness threshold was set. The data is checked more often if the threshold is shorter.
+var stalenessCheckInterval = 10 * time.Second //nolint:gochecknoglobals
+
+// DataFreshness describes how recently the data in Redis was written. See FreshnessReader.
+type DataFreshness struct {
+	// LastUpdated is the time of the last Init or Upsert that changed the data. It is zero if the
+	// data has never been written, or if it was only written by an older version of this
+	// integration or by another SDK.
+	LastUpdated ldtime.UnixMillisecondTime
+	// Age is the time since LastUpdated. It is zero if LastUpdated is zero.
+	Age time.Duration
+	// Stale is true if Age is greater than the threshold that was set with StoreBuilder.StaleAfter.
+	// It is always false if no threshold was set, or if LastUpdated is zero.
+	Stale bool
+}
+
+// FreshnessReader reports how recently the data was written, so that an application that only
+// reads the data, such as an SDK in daemon mode, can detect that whatever process writes the data
+// has stopped doing so:
+//
+//	freshness, err := store.(ldredis.FreshnessReader).GetFreshness()
+type FreshnessReader interface {
+	subsystems.PersistentDataStore
+
+	// GetFreshness returns the time of the last update and whether the data is stale.
+	GetFreshness() (DataFreshness, error)
+}
+
+// stalenessMonitor periodically checks the age of the data, and remembers whether the data was
+// stale when it was last checked, so that a message is only logged when that changes.
+type stalenessMonitor struct {
+	threshold time.Duration
+	lock      sync.Mutex
+	stale     bool
+	started   bool
+	closer    chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+func newStalenessMonitor(threshold time.Duration) *stalenessMonitor {
+	return &stalenessMonitor{threshold: threshold, closer: make(chan struct{}), done: make(chan struct{})}
+}
+
+// GetFreshness returns the time of the last update and whether the data is stale. See
+// FreshnessReader.
+func (store *redisDataStoreImpl) GetFreshness() (DataFreshness, error) {
+	return guarded(store.breaker, func() (DataFreshness, error) {
+		return retried(store.retrier, store.getFreshness)
+	})
+}
+
+func (store *redisDataStoreImpl) getFreshness() (DataFreshness, error) {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	valueStr, err := r.String(c.Do("GET", store.updatedKey()))
+	if err != nil {
+		if err == r.ErrNil {
+			// the data has not been written with a timestamp, which is not a database error
+			err = nil
+		}
+		return DataFreshness{}, err
+	}
+	value, err := strconv.ParseUint(valueStr, 10, 64)
+	if err != nil {
+		return DataFreshness{}, err
+	}
+	freshness := DataFreshness{LastUpdated: ldtime.UnixMillisecondTime(value)}
+	if now := ldtime.UnixMillisNow(); now > freshness.LastUpdated {
+		freshness.Age = time.Duration(now-freshness.LastUpdated) * time.Millisecond
+	}
+	freshness.Stale = store.staleness.threshold > 0 && freshness.Age > store.staleness.threshold
+	return freshness, nil
+}
+
+// startStalenessChecks starts checking the age of the data at intervals, if a staleness threshold
+// was set.
+func (store *redisDataStoreImpl) startStalenessChecks() {
+	m := store.staleness
+	if m.threshold <= 0 {
+		return
+	}
+	m.startOnce.Do(func() {
+		interval := stalenessCheckInterval
+		if m.threshold < interval {
+			interval = m.threshold
+		}
+		m.started = true
+		go func() {
+			defer close(m.done)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-m.closer:
+					return
+				case <-ticker.C:
+					store.checkStaleness()
+				}
+			}
+		}()
+	})
+}
+
+// stopStalenessChecks stops the checks that were started by startStalenessChecks, if any.
+func (store *redisDataStoreImpl) stopStalenessChecks() {
+	m := store.staleness
+	m.stopOnce.Do(func() {
+		close(m.closer)
+		m.startOnce.Do(func() {}) // prevents the checks from being started now
+		if m.started {
+			<-m.done
+		}
+	})
+}
+
+// checkStaleness logs a message if the data has become stale, or is no longer stale, since the
+// last check. If the age cannot be queried, nothing is logged, since the error is reported by
+// whatever operation is next attempted.
+func (store *redisDataStoreImpl) checkStaleness() {
+	freshness, err := guarded(store.breaker, store.getFreshness)
+	if err != nil {
+		return
+	}
+	store.staleness.lock.Lock()
+	wasStale := store.staleness.stale
+	store.staleness.stale = freshness.Stale
+	store.staleness.lock.Unlock()
+	if freshness.Stale && !wasStale {
+		store.loggers.Warnf("Data is stale: it was last updated %s ago, which is more than the limit of %s",
+			freshness.Age.Round(time.Second), store.staleness.threshold)
+	} else if wasStale && !freshness.Stale {
+		store.loggers.Info("Data is no longer stale")
+	}
+}
+
+// sendUpdatedTime queues the command that records the time of an update, as part of a transaction.
+func (store *redisDataStoreImpl) sendUpdatedTime(c r.Conn) {
+	_ = c.Send("SET", store.updatedKey(), uint64(ldtime.UnixMillisNow()))
+}
+
+func (store *redisDataStoreImpl) updatedKey() string {
+	return store.prefix + ":" + updatedKey
+}
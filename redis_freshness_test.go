@@ -0,0 +1,122 @@
+package ldredis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setLastUpdated(t *testing.T, pool ldredistest.ConnPool, age time.Duration) ldtime.UnixMillisecondTime {
+	c := pool.Get()
+	defer c.Close() //nolint:errcheck
+	updated := ldtime.UnixMillisNow() - ldtime.UnixMillisecondTime(age.Milliseconds())
+	_, err := c.Do("SET", DefaultPrefix+":"+updatedKey, uint64(updated))
+	require.NoError(t, err)
+	return updated
+}
+
+func TestFreshnessIsUnknownBeforeData(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore().StaleAfter(time.Minute))
+	freshness, err := FreshnessReader(store).GetFreshness()
+	require.NoError(t, err)
+	assert.Equal(t, DataFreshness{}, freshness)
+	assert.True(t, store.IsStoreAvailable())
+}
+
+func TestFreshnessIsUpdatedByInitAndUpsert(t *testing.T) {
+	pool := ldredistest.NewServer().NewPool()
+	store, _ := buildTestStore(t, DataStore().PoolInterface(pool))
+	before := ldtime.UnixMillisNow()
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	freshness, err := store.GetFreshness()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, freshness.LastUpdated, before)
+	assert.Less(t, freshness.Age, time.Minute)
+	assert.False(t, freshness.Stale)
+
+	// an Upsert that does not change anything does not count as an update
+	updated := setLastUpdated(t, pool, time.Hour)
+	_, err = store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 1).Item)
+	require.NoError(t, err)
+	freshness, err = store.GetFreshness()
+	require.NoError(t, err)
+	assert.Equal(t, updated, freshness.LastUpdated)
+	assert.GreaterOrEqual(t, freshness.Age, time.Hour)
+	assert.False(t, freshness.Stale, "data is never stale without a threshold")
+
+	_, err = store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+	freshness, err = store.GetFreshness()
+	require.NoError(t, err)
+	assert.Less(t, freshness.Age, time.Minute)
+}
+
+func TestStaleDataIsLoggedButStoreStaysAvailable(t *testing.T) {
+	defer func(interval time.Duration) { stalenessCheckInterval = interval }(stalenessCheckInterval)
+	stalenessCheckInterval = 5 * time.Millisecond
+
+	pool := ldredistest.NewServer().NewPool()
+	store, mockLog := buildTestStore(t, DataStore().PoolInterface(pool).StaleAfter(time.Minute))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	setLastUpdated(t, pool, time.Hour)
+	freshness, err := store.GetFreshness()
+	require.NoError(t, err)
+	assert.True(t, freshness.Stale)
+	require.Eventually(t, func() bool { return len(mockLog.GetOutput(ldlog.Warn)) > 0 }, time.Second,
+		5*time.Millisecond)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		"Data is stale: it was last updated 1h0m0s ago, which is more than the limit of 1m0s")
+	assert.True(t, store.IsStoreAvailable())
+	item, err := store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 1).Item.SerializedItem, item.SerializedItem)
+
+	_, err = store.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return mockLog.HasMessageMatch(ldlog.Info, "Data is no longer stale") },
+		time.Second, 5*time.Millisecond)
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 1, "the warning is only logged when the data becomes stale")
+}
+
+func TestStalenessCheckErrorIsIgnored(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, mockLog := buildTestStore(t, DataStore().PoolInterface(faults).StaleAfter(time.Minute))
+	setLastUpdated(t, faults, time.Hour)
+
+	faults.OnCommand("GET").Times(1).Fail(nil)
+	store.checkStaleness()
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 0)
+
+	store.checkStaleness()
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Data is stale")
+}
+
+func TestBuilderReportsFreshness(t *testing.T) {
+	pool := ldredistest.NewServer().NewPool()
+	builder := DataStore().PoolInterface(pool).StaleAfter(time.Minute)
+	_, err := builder.Freshness()
+	assert.EqualError(t, err, "no data store has been built")
+
+	buildTestStore(t, builder)
+	setLastUpdated(t, pool, time.Hour)
+	freshness, err := builder.Freshness()
+	require.NoError(t, err)
+	assert.True(t, freshness.Stale)
+}
+
+func TestGetFreshnessReturnsError(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults))
+
+	faults.OnCommand("GET").Times(1).Fail(nil)
+	_, err := store.GetFreshness()
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+}
@@ -0,0 +1,231 @@
+package ldredis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+)
+
+const (
+	writerKeyPrefix = "$writer:"
+
+	// heartbeatTTLMultiplier is how many heartbeat intervals a writer's record lasts, so that a
+	// record does not expire if a heartbeat is slightly late.
+	heartbeatTTLMultiplier = 3
+)
+
+// WriterInfo describes a data store that has written to Redis recently. See WriterLister.
+type WriterInfo struct {
+	// InstanceID identifies the writer. See StoreBuilder.InstanceID.
+	InstanceID string `json:"instanceId"`
+	// SDKName, SDKVersion and UserAgent identify the SDK that the writer belongs to, as described
+	// for InitMetadata.
+	SDKName    string `json:"sdkName,omitempty"`
+	SDKVersion string `json:"sdkVersion,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	// StartedAt is the time of the writer's first heartbeat, which is the time of its first Init.
+	StartedAt ldtime.UnixMillisecondTime `json:"startedAt"`
+	// HeartbeatAt is the time of the writer's latest heartbeat.
+	HeartbeatAt ldtime.UnixMillisecondTime `json:"heartbeatAt"`
+}
+
+// WriterLister lists the data stores that are writing to the same Redis prefix, if they are sending
+// heartbeats as described in StoreBuilder.WriterHeartbeat:
+//
+//	writers, err := store.(ldredis.WriterLister).GetWriters()
+//
+// Normally there is only one writer, or one writer in each of several redundant Relay Proxy
+// instances. A writer with an unexpected SDK version or User-Agent may be a process that was
+// supposed to have been stopped.
+type WriterLister interface {
+	subsystems.PersistentDataStore
+
+	// GetWriters returns all writers whose latest heartbeat has not expired, sorted by instance ID.
+	GetWriters() ([]WriterInfo, error)
+}
+
+// writerHeartbeat periodically records that a store is writing to Redis. It starts after the
+// store's first Init, because a store that never writes is not a writer.
+type writerHeartbeat struct {
+	store     *redisDataStoreImpl
+	key       string
+	interval  time.Duration
+	info      WriterInfo
+	startOnce sync.Once
+	started   bool
+	closer    chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// newWriterHeartbeat returns nil if heartbeats are not enabled.
+func newWriterHeartbeat(store *redisDataStoreImpl, interval time.Duration) *writerHeartbeat {
+	if interval <= 0 {
+		return nil
+	}
+	return &writerHeartbeat{
+		store:    store,
+		key:      store.prefix + ":" + writerKeyPrefix + store.instanceID,
+		interval: interval,
+		info: WriterInfo{
+			InstanceID: store.instanceID,
+			SDKName:    store.sdk.name,
+			SDKVersion: store.sdk.version,
+			UserAgent:  store.sdk.userAgent,
+		},
+		closer: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// defaultInstanceID returns the host name followed by a random suffix, which distinguishes
+// several processes on the same host.
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" { // COVERAGE: can't cause an error here in unit tests
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
+
+// start sends the first heartbeat and starts sending heartbeats at intervals, unless this has
+// already been done.
+func (h *writerHeartbeat) start() {
+	if h == nil {
+		return
+	}
+	h.startOnce.Do(func() {
+		h.info.StartedAt = ldtime.UnixMillisNow()
+		h.started = true
+		if err := h.beat(); err != nil {
+			h.store.loggers.Warnf("Unable to record writer heartbeat: %s", err)
+		}
+		h.store.loggers.Infof("Sending writer heartbeats as %q", h.info.InstanceID)
+		go h.run()
+	})
+}
+
+func (h *writerHeartbeat) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.closer:
+			return
+		case <-ticker.C:
+			if err := h.beat(); err != nil {
+				h.store.loggers.Warnf("Unable to record writer heartbeat: %s", err)
+			}
+		}
+	}
+}
+
+func (h *writerHeartbeat) beat() error {
+	info := h.info
+	info.HeartbeatAt = ldtime.UnixMillisNow()
+	data, _ := json.Marshal(info)
+	ttl := h.interval * heartbeatTTLMultiplier
+
+	c := h.store.getConn()
+	defer c.Close() // nolint:errcheck
+	_, err := c.Do("SET", h.key, data, "PX", ttl.Milliseconds())
+	return err
+}
+
+// stop stops the heartbeats and deletes the writer's record, so that the writer is no longer listed.
+func (h *writerHeartbeat) stop() {
+	if h == nil {
+		return
+	}
+	h.stopOnce.Do(func() {
+		close(h.closer)
+		h.startOnce.Do(func() {}) // prevents a concurrent Init from starting the heartbeat now
+		if h.started {
+			<-h.done // so that a heartbeat that is in progress cannot recreate the record
+			c := h.store.getConn()
+			defer c.Close() // nolint:errcheck
+			_, _ = c.Do("DEL", h.key)
+		}
+	})
+}
+
+// GetWriters returns the writers that are sending heartbeats. See WriterLister.
+func (store *redisDataStoreImpl) GetWriters() ([]WriterInfo, error) {
+	return guarded(store.breaker, func() ([]WriterInfo, error) {
+		return retried(store.retrier, store.getWriters)
+	})
+}
+
+func (store *redisDataStoreImpl) getWriters() ([]WriterInfo, error) {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	pattern := escapeGlob(store.prefix+":"+writerKeyPrefix) + "*"
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := r.Values(c.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+		if len(reply) != 2 { // COVERAGE: can't cause an error here in unit tests
+			return nil, errors.New("unexpected SCAN reply")
+		}
+		if cursor, err = r.String(reply[0], nil); err != nil { // COVERAGE: can't cause an error here in unit tests
+			return nil, err
+		}
+		batch, err := r.Strings(reply[1], nil)
+		if err != nil { // COVERAGE: can't cause an error here in unit tests
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		if cursor == "0" {
+			break
+		}
+	}
+
+	writers := make([]WriterInfo, 0, len(keys))
+	for _, key := range keys {
+		data, err := r.Bytes(c.Do("GET", key))
+		if err == r.ErrNil {
+			continue // the record expired after it was found
+		}
+		if err != nil {
+			return nil, err
+		}
+		var info WriterInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			store.loggers.Warnf("Ignoring writer record %q that is not valid: %s", key, err)
+			continue
+		}
+		writers = append(writers, info)
+	}
+	sort.Slice(writers, func(i, j int) bool { return writers[i].InstanceID < writers[j].InstanceID })
+	return writers, nil
+}
+
+// escapeGlob escapes the characters that have a special meaning in a Redis key pattern.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, ch := range s {
+		if strings.ContainsRune(`*?[]\`, ch) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
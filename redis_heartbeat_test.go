@@ -0,0 +1,106 @@
+package ldredis
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterHeartbeatStartsAfterInit(t *testing.T) {
+	server := ldredistest.NewServer()
+	interval := 10 * time.Millisecond
+	store1, mockLog := buildTestStore(t, DataStore().PoolInterface(server.NewPool()).WriterHeartbeat(interval).
+		InstanceID("a"))
+	store2, _ := buildTestStore(t, DataStore().PoolInterface(server.NewPool()).WriterHeartbeat(interval).
+		InstanceID("b"))
+
+	writers, err := store1.GetWriters()
+	require.NoError(t, err)
+	assert.Len(t, writers, 0)
+
+	require.NoError(t, store1.Init(makeTestFlagData()))
+	require.NoError(t, store2.Init(makeTestFlagData()))
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, `Sending writer heartbeats as "a"`)
+
+	writers, err = store2.GetWriters()
+	require.NoError(t, err)
+	require.Len(t, writers, 2)
+	assert.Equal(t, "a", writers[0].InstanceID)
+	assert.Equal(t, "b", writers[1].InstanceID)
+	assert.Equal(t, "GoClient", writers[0].SDKName)
+	assert.Equal(t, "7.0.0", writers[0].SDKVersion)
+	assert.Equal(t, testUserAgent, writers[0].UserAgent)
+	assert.GreaterOrEqual(t, writers[0].HeartbeatAt, writers[0].StartedAt)
+
+	require.Eventually(t, func() bool {
+		writers, err := store2.GetWriters()
+		return err == nil && len(writers) == 2 && writers[0].HeartbeatAt > writers[0].StartedAt
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, store1.Close())
+	writers, err = store2.GetWriters()
+	require.NoError(t, err)
+	require.Len(t, writers, 1)
+	assert.Equal(t, "b", writers[0].InstanceID)
+}
+
+func TestNoWriterHeartbeatByDefault(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore())
+	require.NoError(t, store.Init(makeTestFlagData()))
+
+	writers, err := store.GetWriters()
+	require.NoError(t, err)
+	assert.Len(t, writers, 0)
+}
+
+func TestExpiredWriterIsNotListed(t *testing.T) {
+	server := ldredistest.NewServer()
+	store, mockLog := buildTestStore(t, DataStore().PoolInterface(server.NewPool()))
+	c := server.NewPool().Get()
+	defer c.Close() //nolint:errcheck
+	_, err := c.Do("SET", DefaultPrefix+":"+writerKeyPrefix+"zombie", `{"instanceId":"zombie"}`, "PX", 10)
+	require.NoError(t, err)
+	_, err = c.Do("SET", DefaultPrefix+":"+writerKeyPrefix+"bad", "not JSON")
+	require.NoError(t, err)
+
+	writers, err := store.GetWriters()
+	require.NoError(t, err)
+	assert.Equal(t, []WriterInfo{{InstanceID: "zombie"}}, writers)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		`Ignoring writer record "launchdarkly:\$writer:bad" that is not valid`)
+
+	time.Sleep(20 * time.Millisecond)
+	writers, err = store.GetWriters()
+	require.NoError(t, err)
+	assert.Len(t, writers, 0)
+}
+
+func TestWritersAreListedOnlyForSamePrefix(t *testing.T) {
+	server := ldredistest.NewServer()
+	store1, _ := buildTestStore(t,
+		DataStore().PoolInterface(server.NewPool()).Prefix("a*").WriterHeartbeat(time.Hour).InstanceID("x"))
+	store2, _ := buildTestStore(t,
+		DataStore().PoolInterface(server.NewPool()).Prefix("ab").WriterHeartbeat(time.Hour).InstanceID("y"))
+	require.NoError(t, store1.Init(makeTestFlagData()))
+	require.NoError(t, store2.Init(makeTestFlagData()))
+
+	writers, err := store1.GetWriters()
+	require.NoError(t, err)
+	require.Len(t, writers, 1)
+	assert.Equal(t, "x", writers[0].InstanceID)
+}
+
+func TestDefaultInstanceID(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	id1, id2 := defaultInstanceID(), defaultInstanceID()
+	assert.True(t, strings.HasPrefix(id1, hostname+"-"))
+	assert.NotEqual(t, id1, id2)
+}
@@ -12,20 +12,76 @@ import (
 
 // Internal implementation of the PersistentDataStore interface for Redis.
 type redisDataStoreImpl struct {
-	prefix     string
-	pool       Pool
-	loggers    ldlog.Loggers
-	testTxHook func()
+	prefix       string
+	pool         Pool
+	idleConns    *idleConnMaintainer
+	breaker      *circuitBreaker
+	retrier      *readRetrier
+	readOnly     ReadOnlyMode
+	scan         scanOptions
+	initChunk    int
+	initDiffMode InitDiffMode
+	sdk          sdkInfo
+	staleness    *stalenessMonitor
+	instanceID   string
+	heartbeat    *writerHeartbeat
+	lease        *writerLease
+	loggers      ldlog.Loggers
+	testTxHook   func()
 }
 
-func newPool(url string, dialOptions []r.DialOption) *r.Pool {
+// These are the limits of the pool that is created if no pool is specified, unless they are
+// changed with StoreBuilder.PoolLimits.
+const (
+	defaultMaxIdle     = 20
+	defaultMaxActive   = 16
+	defaultIdleTimeout = 300 * time.Second
+)
+
+type poolLimitsOptions struct {
+	maxActive   int
+	maxIdle     int
+	idleTimeout time.Duration
+	minIdle     int
+}
+
+func (o poolLimitsOptions) getMaxActive() int {
+	if o.maxActive <= 0 {
+		return defaultMaxActive
+	}
+	return o.maxActive
+}
+
+func (o poolLimitsOptions) getMaxIdle() int {
+	if o.maxIdle <= 0 {
+		return defaultMaxIdle
+	}
+	return o.maxIdle
+}
+
+func (o poolLimitsOptions) getIdleTimeout() time.Duration {
+	if o.idleTimeout <= 0 {
+		return defaultIdleTimeout
+	}
+	return o.idleTimeout
+}
+
+func newPool(builder builderOptions, loggers ldlog.Loggers) *r.Pool {
+	url, dialOptions := builder.effectiveURL(), builder.dialOptions
+	dialURL := r.DialURL
+	switch {
+	case isUnixSocketURL(url):
+		dialURL = dialUnixSocketURL
+	case builder.tls.isEnabled():
+		dialURL = newTLSConfigLoader(builder.tls, loggers).dialURL
+	}
 	pool := &r.Pool{
-		MaxIdle:     20,
-		MaxActive:   16,
+		MaxIdle:     builder.poolLimits.getMaxIdle(),
+		MaxActive:   builder.poolLimits.getMaxActive(),
 		Wait:        true,
-		IdleTimeout: 300 * time.Second,
+		IdleTimeout: builder.poolLimits.getIdleTimeout(),
 		Dial: func() (c r.Conn, err error) {
-			c, err = r.DialURL(url, dialOptions...)
+			c, err = dialURL(url, dialOptions...)
 			return
 		},
 		TestOnBorrow: func(c r.Conn, t time.Time) error {
@@ -33,6 +89,9 @@ func newPool(url string, dialOptions []r.DialOption) *r.Pool {
 			return err
 		},
 	}
+	if builder.credentialsProvider != nil {
+		newCredentialsDialer(builder, dialURL, loggers).configurePool(pool)
+	}
 	return pool
 }
 
@@ -43,21 +102,41 @@ func newRedisDataStoreImpl(
 	loggers ldlog.Loggers,
 ) *redisDataStoreImpl {
 	impl := &redisDataStoreImpl{
-		prefix:  builder.prefix,
-		pool:    builder.pool,
-		loggers: loggers,
+		prefix:       builder.prefix,
+		pool:         builder.pool,
+		readOnly:     builder.readOnly,
+		scan:         builder.scan,
+		initChunk:    builder.initChunkSize,
+		initDiffMode: builder.initDiffMode,
+		staleness:    newStalenessMonitor(builder.staleAfter),
+		loggers:      loggers,
 	}
 	impl.loggers.SetPrefix("RedisDataStore:")
+	if impl.readOnly != ReadWrite {
+		impl.loggers.Infof("Data store is %s", impl.readOnly)
+	}
 
 	if impl.pool == nil {
-		logRedisURL(loggers, builder.url)
-		impl.pool = newPool(builder.url, builder.dialOptions)
+		logRedisURL(loggers, builder.effectiveURL())
+		pool := newPool(builder, impl.loggers)
+		impl.pool = pool
+		impl.idleConns = startIdleConnMaintainer(pool, builder.poolLimits.minIdle, minIdleCheckInterval, impl.loggers)
 	}
+	impl.breaker = newCircuitBreaker(builder.circuitBreaker, impl.checkAvailable, impl.loggers)
+	impl.retrier = newReadRetrier(builder.readRetry, impl.loggers)
 	return impl
 }
 
 func logRedisURL(loggers ldlog.Loggers, redisURL string) {
 	if parsed, err := url.Parse(redisURL); err == nil {
+		if parsed.Scheme == unixSocketScheme {
+			if db := parsed.Query().Get("db"); db != "" {
+				loggers.Infof("Using Unix socket: %s (database %s)", parsed.Path, db)
+			} else {
+				loggers.Infof("Using Unix socket: %s", parsed.Path)
+			}
+			return
+		}
 		loggers.Infof("Using URL: %s", parsed.Redacted())
 	} else {
 		loggers.Errorf("Invalid Redis URL: %s", redisURL) // we can assume that the Redis client will also fail
@@ -65,38 +144,48 @@ func logRedisURL(loggers ldlog.Loggers, redisURL string) {
 }
 
 func (store *redisDataStoreImpl) Init(allData []ldstoretypes.SerializedCollection) error {
-	c := store.getConn()
-	defer c.Close() // nolint:errcheck
-
-	_ = c.Send("MULTI")
-
-	totalCount := 0
-
-	for _, coll := range allData {
-		baseKey := store.featuresKey(coll.Kind)
-
-		_ = c.Send("DEL", baseKey)
-
-		totalCount += len(coll.Items)
-		for _, keyedItem := range coll.Items {
-			_ = c.Send("HSET", baseKey, keyedItem.Key, keyedItem.Item.SerializedItem)
-		}
+	if ok, err := store.checkWrite("Init"); !ok {
+		return err
 	}
-
-	_ = c.Send("SET", store.initedKey(), "")
-
-	_, err := c.Do("EXEC")
-
-	if err == nil {
-		store.loggers.Infof("Initialized with %d items", totalCount)
+	token, ok := store.checkLease("Init", func(d *dataCopy) { d.init(allData) })
+	if !ok {
+		return nil
 	}
+	defer store.lease.endWrite()
+	return store.writeInit(allData, token)
+}
 
+// writeInit writes all of the data, using the specified fencing token if the writer lease is
+// enabled.
+func (store *redisDataStoreImpl) writeInit(allData []ldstoretypes.SerializedCollection, token int64) error {
+	err := guardedErr(store.breaker, func() error {
+		return retryFenced(func() error {
+			if store.initDiffMode != InitReplaceAll {
+				return store.initDiff(allData, token)
+			}
+			return store.init(allData, token)
+		})
+	})
+	if err == nil && token == store.lease.currentToken() {
+		store.heartbeat.start()
+	}
 	return err
 }
 
 func (store *redisDataStoreImpl) Get(
 	kind ldstoretypes.DataKind,
 	key string,
+) (ldstoretypes.SerializedItemDescriptor, error) {
+	return guarded(store.breaker, func() (ldstoretypes.SerializedItemDescriptor, error) {
+		return retried(store.retrier, func() (ldstoretypes.SerializedItemDescriptor, error) {
+			return store.get(kind, key)
+		})
+	})
+}
+
+func (store *redisDataStoreImpl) get(
+	kind ldstoretypes.DataKind,
+	key string,
 ) (ldstoretypes.SerializedItemDescriptor, error) {
 	c := store.getConn()
 	defer c.Close() // nolint:errcheck
@@ -119,6 +208,23 @@ func (store *redisDataStoreImpl) Get(
 func (store *redisDataStoreImpl) GetAll(
 	kind ldstoretypes.DataKind,
 ) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	return guarded(store.breaker, func() ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+		return retried(store.retrier, func() ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+			return store.getAll(kind)
+		})
+	})
+}
+
+func (store *redisDataStoreImpl) getAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	if scan, err := store.shouldScan(store.featuresKey(kind)); err != nil || scan {
+		if err != nil {
+			return nil, err
+		}
+		return store.scanAll(kind)
+	}
+
 	c := store.getConn()
 	defer c.Close() // nolint:errcheck
 
@@ -142,6 +248,25 @@ func (store *redisDataStoreImpl) Upsert(
 	kind ldstoretypes.DataKind,
 	key string,
 	newItem ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	if ok, err := store.checkWrite("Upsert"); !ok {
+		return false, err
+	}
+	token, ok := store.checkLease("Upsert", func(d *dataCopy) { d.upsert(kind, key, newItem) })
+	if !ok {
+		return false, nil
+	}
+	defer store.lease.endWrite()
+	return guarded(store.breaker, func() (bool, error) {
+		return store.upsert(kind, key, newItem, token)
+	})
+}
+
+func (store *redisDataStoreImpl) upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	newItem ldstoretypes.SerializedItemDescriptor,
+	fence int64,
 ) (bool, error) {
 	baseKey := store.featuresKey(kind)
 	for {
@@ -156,11 +281,16 @@ func (store *redisDataStoreImpl) Upsert(
 
 		defer c.Send("UNWATCH") // nolint:errcheck // this should always succeed
 
+		fenceToSet, ok, err := store.watchFence(c, fence)
+		if !ok {
+			return false, err
+		}
+
 		if store.testTxHook != nil { // instrumentation for unit tests
 			store.testTxHook()
 		}
 
-		oldItem, err := store.Get(kind, key)
+		oldItem, err := store.get(kind, key)
 		if err != nil { // COVERAGE: can't cause an error here in unit tests
 			return false, err
 		}
@@ -187,6 +317,8 @@ func (store *redisDataStoreImpl) Upsert(
 		_ = c.Send("MULTI")
 		err = c.Send("HSET", baseKey, key, newItem.SerializedItem)
 		if err == nil {
+			store.sendUpdatedTime(c)
+			store.sendFence(c, fenceToSet)
 			var result interface{}
 			result, err = c.Do("EXEC")
 			if err == nil {
@@ -205,23 +337,47 @@ func (store *redisDataStoreImpl) Upsert(
 }
 
 func (store *redisDataStoreImpl) IsInitialized() bool {
-	c := store.getConn()
-	defer c.Close() // nolint:errcheck
-	inited, _ := r.Bool(c.Do("EXISTS", store.initedKey()))
+	inited, _ := guarded(store.breaker, func() (bool, error) {
+		return retried(store.retrier, store.isInitialized)
+	})
 	return inited
 }
 
-func (store *redisDataStoreImpl) IsStoreAvailable() bool {
+func (store *redisDataStoreImpl) isInitialized() (bool, error) {
 	c := store.getConn()
 	defer c.Close() // nolint:errcheck
-	_, err := r.Bool(c.Do("EXISTS", store.initedKey()))
+	return r.Bool(c.Do("EXISTS", store.initedKey()))
+}
+
+// IsStoreAvailable returns true if Redis can be queried. Stale data does not make the store
+// unavailable; see StoreBuilder.StaleAfter.
+func (store *redisDataStoreImpl) IsStoreAvailable() bool {
+	_, err := guarded(store.breaker, store.isInitialized)
+	return err == nil
+}
+
+// checkAvailable is used by the circuit breaker to find out whether Redis is available again. It
+// performs the same query as IsStoreAvailable, but is not subject to the circuit breaker.
+func (store *redisDataStoreImpl) checkAvailable() bool {
+	_, err := store.isInitialized()
 	return err == nil
 }
 
 func (store *redisDataStoreImpl) Close() error {
+	store.stopStalenessChecks()
+	store.lease.stop()
+	store.heartbeat.stop()
+	store.idleConns.stop()
+	logPoolStatsOnClose(store.pool, store.loggers)
 	return store.pool.Close()
 }
 
+// PoolStats returns the current state of the store's connection pool, or false if the pool does
+// not provide statistics. See PoolWithStats.
+func (store *redisDataStoreImpl) PoolStats() (PoolStats, bool) {
+	return getPoolStats(store.pool)
+}
+
 func (store *redisDataStoreImpl) featuresKey(kind ldstoretypes.DataKind) string {
 	return store.prefix + ":" + kind.GetName()
 }
@@ -231,5 +387,5 @@ func (store *redisDataStoreImpl) initedKey() string {
 }
 
 func (store *redisDataStoreImpl) getConn() r.Conn {
-	return store.pool.Get()
+	return getPoolConn(store.pool, store.loggers)
 }
@@ -0,0 +1,248 @@
+package ldredis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+const (
+	// initPipelineDepth is the number of chunks that are sent before waiting for their replies.
+	initPipelineDepth = 8
+
+	// initProgressInterval is how many items Init writes between progress messages.
+	initProgressInterval = 100000
+
+	// hdelChunkSize is the maximum number of items that a diff-based Init deletes with a single
+	// HDEL command, if StoreBuilder.InitChunkSize is not set.
+	hdelChunkSize = 1000
+
+	// stagingKeyTTL is how long a temporary key is kept if the Init that wrote it does not finish,
+	// for instance because the process stopped.
+	stagingKeyTTL = time.Hour
+
+	stagingKeyPrefix = "$init:"
+)
+
+func (store *redisDataStoreImpl) init(allData []ldstoretypes.SerializedCollection, fence int64) error {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	fenceToSet, ok, err := store.watchFence(c, fence)
+	if !ok {
+		return err
+	}
+
+	chunkSize := store.initChunk
+	totalCount := 0
+	for _, coll := range allData {
+		totalCount += len(coll.Items)
+	}
+	progress := newInitProgress(store.loggers, totalCount, initProgressInterval)
+
+	// If a chunk size was set, collections that are larger than that are written to temporary keys
+	// first, so that the transaction below only has to rename them. Each Init uses its own temporary
+	// keys, so that Inits by several instances at the same time cannot write to the same ones.
+	stagingID := newStagingID()
+	staged := make([]string, len(allData))
+	defer func() {
+		store.deleteStagingKeys(c, staged)
+	}()
+	for i, coll := range allData {
+		if chunkSize > 0 && len(coll.Items) > chunkSize {
+			staged[i] = store.stagingKey(stagingID, coll.Kind)
+			if err := store.stageCollection(c, staged[i], coll, chunkSize, progress); err != nil {
+				return err
+			}
+		}
+	}
+
+	_ = c.Send("MULTI")
+
+	for i, coll := range allData {
+		baseKey := store.featuresKey(coll.Kind)
+		if staged[i] != "" {
+			_ = c.Send("RENAME", staged[i], baseKey)
+			_ = c.Send("PERSIST", baseKey) // RENAME keeps the expiry time of the temporary key
+			continue
+		}
+		_ = c.Send("DEL", baseKey)
+		store.sendHSet(c, baseKey, coll.Items)
+	}
+
+	store.sendInitMetadata(c, allData)
+	store.sendUpdatedTime(c)
+	store.sendFence(c, fenceToSet)
+	_ = c.Send("SET", store.initedKey(), "")
+
+	result, err := c.Do("EXEC")
+	if err == nil {
+		err = execError(result)
+	}
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return errFenceChanged
+	}
+	staged = nil // the temporary keys were renamed, so there is nothing to delete
+	store.loggers.Infof("Initialized with %d items", totalCount)
+	return nil
+}
+
+// stageCollection writes the items of a collection to a temporary key, which expires after
+// stagingKeyTTL in case the Init does not finish.
+func (store *redisDataStoreImpl) stageCollection(
+	c r.Conn,
+	key string,
+	coll ldstoretypes.SerializedCollection,
+	chunkSize int,
+	progress *initProgress,
+) error {
+	p := pipeline{conn: c, depth: initPipelineDepth}
+	var err error
+	forEachChunk(len(coll.Items), chunkSize, func(start, end int) {
+		if err == nil {
+			err = p.send("HSET", hsetArgs(key, coll.Items[start:end])...)
+			progress.add(end - start)
+		}
+		if err == nil && start == 0 {
+			err = p.send("PEXPIRE", key, stagingKeyTTL.Milliseconds())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return p.flush()
+}
+
+// sendHSet queues the HSET commands that set the specified items, as part of a transaction. Unless
+// StoreBuilder.InitChunkSize was set, each item is set with its own command, since HSET only
+// accepts several fields in Redis 4.0 or later.
+func (store *redisDataStoreImpl) sendHSet(c r.Conn, key string, items []ldstoretypes.KeyedSerializedItemDescriptor) {
+	chunkSize := store.initChunk
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+	forEachChunk(len(items), chunkSize, func(start, end int) {
+		_ = c.Send("HSET", hsetArgs(key, items[start:end])...)
+	})
+}
+
+// deleteStagingKeys deletes the temporary keys of an Init that failed, if it had written any.
+func (store *redisDataStoreImpl) deleteStagingKeys(c r.Conn, keys []string) {
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if key != "" {
+			args = append(args, key)
+		}
+	}
+	if len(args) > 0 {
+		_, _ = c.Do("DEL", args...)
+	}
+}
+
+func (store *redisDataStoreImpl) stagingKey(stagingID string, kind ldstoretypes.DataKind) string {
+	return store.prefix + ":" + stagingKeyPrefix + stagingID + ":" + kind.GetName()
+}
+
+// newStagingID returns a random string that distinguishes the temporary keys of one Init from
+// those of any other Init.
+func newStagingID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// execError returns the first error in the reply to an EXEC command, if any. An error in one of
+// the commands of a transaction does not cause EXEC itself to fail, and does not prevent the other
+// commands from being executed.
+func execError(result interface{}) error {
+	replies, _ := result.([]interface{})
+	for _, reply := range replies {
+		if err, ok := reply.(r.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachChunk calls fn with the bounds of each chunk of at most size elements, out of n elements.
+func forEachChunk(n, size int, fn func(start, end int)) {
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		fn(start, end)
+	}
+}
+
+// hsetArgs returns the arguments for an HSET command that sets all of the specified items.
+func hsetArgs(key string, items []ldstoretypes.KeyedSerializedItemDescriptor) []interface{} {
+	args := make([]interface{}, 0, 1+2*len(items))
+	args = append(args, key)
+	for _, item := range items {
+		args = append(args, item.Key, item.Item.SerializedItem)
+	}
+	return args
+}
+
+// pipeline sends commands on a connection without waiting for each reply, and reads the replies
+// once depth commands are pending.
+type pipeline struct {
+	conn    r.Conn
+	depth   int
+	pending int
+}
+
+func (p *pipeline) send(commandName string, args ...interface{}) error {
+	if err := p.conn.Send(commandName, args...); err != nil {
+		return err
+	}
+	p.pending++
+	if p.pending >= p.depth {
+		return p.flush()
+	}
+	return nil
+}
+
+// flush reads the replies to all pending commands, and returns the first error, if any.
+func (p *pipeline) flush() error {
+	if err := p.conn.Flush(); err != nil { // COVERAGE: can't cause an error here in unit tests
+		return err
+	}
+	var firstErr error
+	for ; p.pending > 0; p.pending-- {
+		if _, err := p.conn.Receive(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// initProgress logs how many items Init has written, for payloads that are large enough to take
+// a noticeable time.
+type initProgress struct {
+	loggers  ldlog.Loggers
+	total    int
+	interval int
+	written  int
+}
+
+func newInitProgress(loggers ldlog.Loggers, total, interval int) *initProgress {
+	return &initProgress{loggers: loggers, total: total, interval: interval}
+}
+
+func (p *initProgress) add(n int) {
+	before := p.written
+	p.written += n
+	if p.written/p.interval > before/p.interval {
+		p.loggers.Infof("Init has written %d of %d items", p.written, p.total)
+	}
+}
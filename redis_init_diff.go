@@ -0,0 +1,193 @@
+package ldredis
+
+import (
+	"crypto/sha256"
+	"sort"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// InitDiffMode specifies whether Init replaces all of the stored data, or only writes the items
+// that have changed. See StoreBuilder.InitDiff.
+type InitDiffMode int
+
+const (
+	// InitReplaceAll means that Init deletes each collection and writes all of its items. This is
+	// the default.
+	InitReplaceAll InitDiffMode = iota
+	// InitDiffByVersion means that Init only writes items whose version is different from the
+	// version of the stored item. The stored items must be parsed to find their versions.
+	InitDiffByVersion
+	// InitDiffByHash means that Init only writes items whose serialized data is different from the
+	// stored data, as determined by comparing SHA-256 hashes.
+	InitDiffByHash
+)
+
+// maxInitDiffAttempts is how many times a diff-based Init is attempted if the data is modified
+// while it is being compared, before all of the data is replaced instead.
+const maxInitDiffAttempts = 3
+
+// String returns a description of the mode, such as "replace all".
+func (m InitDiffMode) String() string {
+	switch m {
+	case InitReplaceAll:
+		return "replace all"
+	case InitDiffByVersion:
+		return "diff by version"
+	case InitDiffByHash:
+		return "diff by hash"
+	default:
+		return "unknown"
+	}
+}
+
+// collectionDiff is the set of changes that make a stored collection match a new one.
+type collectionDiff struct {
+	changed []ldstoretypes.KeyedSerializedItemDescriptor
+	removed []string
+}
+
+// itemFingerprint is what is compared to decide whether an item has changed: either its version
+// or its hash, depending on the InitDiffMode.
+type itemFingerprint struct {
+	version int
+	hash    [sha256.Size]byte
+}
+
+func (store *redisDataStoreImpl) initDiff(allData []ldstoretypes.SerializedCollection, fence int64) error {
+	for attempt := 1; ; attempt++ {
+		done, err := store.tryInitDiff(allData, fence)
+		if err != nil || done {
+			return err
+		}
+		if attempt >= maxInitDiffAttempts {
+			store.loggers.Warnf("Data was modified during %d attempts at a diff-based Init; replacing all data instead",
+				attempt)
+			return store.init(allData, fence)
+		}
+		if store.loggers.IsDebugEnabled() { // COVERAGE: tests don't verify debug logging
+			store.loggers.Debug("Concurrent modification detected during Init, retrying")
+		}
+	}
+}
+
+// tryInitDiff compares the new data with the stored data, and then writes the differences in a
+// transaction. It returns false if the transaction was aborted because the stored data was
+// modified in the meantime.
+func (store *redisDataStoreImpl) tryInitDiff(
+	allData []ldstoretypes.SerializedCollection,
+	fence int64,
+) (bool, error) {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	watchKeys := make([]interface{}, 0, len(allData)+1)
+	for _, coll := range allData {
+		watchKeys = append(watchKeys, store.featuresKey(coll.Kind))
+	}
+	watchKeys = append(watchKeys, store.initedKey())
+	if _, err := c.Do("WATCH", watchKeys...); err != nil {
+		return false, err
+	}
+	defer c.Send("UNWATCH") // nolint:errcheck // this should always succeed
+
+	fenceToSet, ok, err := store.watchFence(c, fence)
+	if !ok {
+		return true, err // another instance holds the lease, so there is nothing more to do
+	}
+
+	if store.testTxHook != nil { // instrumentation for unit tests
+		store.testTxHook()
+	}
+
+	diffs := make([]collectionDiff, len(allData))
+	for i, coll := range allData {
+		diff, err := store.diffCollection(coll)
+		if err != nil {
+			return false, err
+		}
+		diffs[i] = diff
+	}
+
+	chunkSize := store.initChunk
+	if chunkSize <= 0 {
+		chunkSize = hdelChunkSize
+	}
+	totalCount, changedCount, removedCount := 0, 0, 0
+	_ = c.Send("MULTI")
+	for i, coll := range allData {
+		baseKey := store.featuresKey(coll.Kind)
+		diff := diffs[i]
+		store.sendHSet(c, baseKey, diff.changed)
+		forEachChunk(len(diff.removed), chunkSize, func(start, end int) {
+			args := make([]interface{}, 0, 1+end-start)
+			args = append(args, baseKey)
+			for _, key := range diff.removed[start:end] {
+				args = append(args, key)
+			}
+			_ = c.Send("HDEL", args...)
+		})
+		totalCount += len(coll.Items)
+		changedCount += len(diff.changed)
+		removedCount += len(diff.removed)
+	}
+	store.sendInitMetadata(c, allData)
+	store.sendUpdatedTime(c)
+	store.sendFence(c, fenceToSet)
+	_ = c.Send("SET", store.initedKey(), "")
+
+	result, err := c.Do("EXEC")
+	if err == nil {
+		err = execError(result)
+	}
+	if err != nil || result == nil {
+		return false, err
+	}
+	store.loggers.Infof("Initialized with %d items (%d added or changed, %d removed)",
+		totalCount, changedCount, removedCount)
+	return true, nil
+}
+
+// diffCollection reads the stored items of a kind, and returns the changes that are needed to make
+// them match the specified collection. Only the fingerprints of the stored items are kept in memory.
+func (store *redisDataStoreImpl) diffCollection(coll ldstoretypes.SerializedCollection) (collectionDiff, error) {
+	stored := make(map[string]itemFingerprint)
+	it := store.Iterate(coll.Kind)
+	for it.Next() {
+		item := it.Item()
+		version := -1
+		if store.initDiffMode == InitDiffByVersion {
+			if parsed, err := coll.Kind.Deserialize(item.Item.SerializedItem); err == nil {
+				version = parsed.Version
+			}
+		}
+		stored[item.Key] = store.fingerprint(item.Item, version)
+	}
+	if err := it.Err(); err != nil {
+		return collectionDiff{}, err
+	}
+
+	var diff collectionDiff
+	for _, item := range coll.Items {
+		old, ok := stored[item.Key]
+		delete(stored, item.Key)
+		if !ok || old != store.fingerprint(item.Item, item.Item.Version) {
+			diff.changed = append(diff.changed, item)
+		}
+	}
+	for key := range stored {
+		diff.removed = append(diff.removed, key)
+	}
+	sort.Strings(diff.removed)
+	return diff, nil
+}
+
+func (store *redisDataStoreImpl) fingerprint(
+	item ldstoretypes.SerializedItemDescriptor,
+	version int,
+) itemFingerprint {
+	if store.initDiffMode == InitDiffByHash {
+		return itemFingerprint{hash: sha256.Sum256(item.SerializedItem)}
+	}
+	return itemFingerprint{version: version}
+}
@@ -0,0 +1,148 @@
+package ldredis
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getAllFlags(t *testing.T, store subsystems.PersistentDataStore) map[string][]byte {
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	result := make(map[string][]byte, len(items))
+	for _, item := range items {
+		result[item.Key] = item.Item.SerializedItem
+	}
+	return result
+}
+
+// withSerializedItem returns the flag with different serialized data but the same version.
+func withSerializedItem(
+	flag ldstoretypes.KeyedSerializedItemDescriptor,
+	data string,
+) ldstoretypes.KeyedSerializedItemDescriptor {
+	flag.Item.SerializedItem = []byte(data)
+	return flag
+}
+
+func TestInitDiffWritesOnlyChanges(t *testing.T) {
+	for _, mode := range []InitDiffMode{InitDiffByVersion, InitDiffByHash} {
+		t.Run(mode.String(), func(t *testing.T) {
+			store, mockLog := buildTestStore(t, DataStore().InitDiff(mode).InitChunkSize(2))
+			defer mockLog.DumpIfTestFailed(t)
+			require.NoError(t, store.Init(makeTestFlagData(
+				makeTestFlag("flag0", 1), makeTestFlag("flag1", 1), makeTestFlag("flag2", 1))))
+			mockLog.AssertMessageMatch(t, true, ldlog.Info, `Initialized with 3 items \(3 added or changed, 0 removed\)`)
+
+			require.NoError(t, store.Init(makeTestFlagData(
+				makeTestFlag("flag0", 1), makeTestFlag("flag1", 2), makeTestFlag("flag3", 1))))
+			mockLog.AssertMessageMatch(t, true, ldlog.Info, `Initialized with 3 items \(2 added or changed, 1 removed\)`)
+
+			assert.Equal(t, map[string][]byte{
+				"flag0": makeTestFlag("flag0", 1).Item.SerializedItem,
+				"flag1": makeTestFlag("flag1", 2).Item.SerializedItem,
+				"flag3": makeTestFlag("flag3", 1).Item.SerializedItem,
+			}, getAllFlags(t, store))
+			assert.True(t, store.IsInitialized())
+		})
+	}
+}
+
+func TestInitDiffByHashDetectsChangeWithSameVersion(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore().InitDiff(InitDiffByHash).InitChunkSize(2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag0", 1))))
+
+	changed := withSerializedItem(makeTestFlag("flag0", 1), `{"key":"flag0","version":1,"on":true}`)
+	require.NoError(t, store.Init(makeTestFlagData(changed)))
+	assert.Equal(t, map[string][]byte{"flag0": changed.Item.SerializedItem}, getAllFlags(t, store))
+}
+
+func TestInitDiffByVersionIgnoresChangeWithSameVersion(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore().InitDiff(InitDiffByVersion).InitChunkSize(2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag0", 1))))
+
+	changed := withSerializedItem(makeTestFlag("flag0", 1), `{"key":"flag0","version":1,"on":true}`)
+	require.NoError(t, store.Init(makeTestFlagData(changed)))
+	assert.Equal(t, map[string][]byte{"flag0": makeTestFlag("flag0", 1).Item.SerializedItem}, getAllFlags(t, store))
+}
+
+func TestInitDiffRemovesAllItems(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore().InitDiff(InitDiffByVersion).InitChunkSize(2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(5, 1)...)))
+
+	require.NoError(t, store.Init(makeTestFlagData()))
+	assert.Len(t, getAllFlags(t, store), 0)
+	assert.True(t, store.IsInitialized())
+}
+
+func TestInitDiffRetriesAfterConcurrentModification(t *testing.T) {
+	store, mockLog := buildTestStore(t, DataStore().InitDiff(InitDiffByVersion).InitChunkSize(2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag0", 1))))
+
+	other, err := DataStore().PoolInterface(store.pool).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	attempts := 0
+	store.testTxHook = func() {
+		attempts++
+		if attempts == 1 {
+			_, err := other.Upsert(ldstoreimpl.Features(), "flag9", makeTestFlag("flag9", 1).Item)
+			require.NoError(t, err)
+		}
+	}
+
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag0", 2))))
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, map[string][]byte{"flag0": makeTestFlag("flag0", 2).Item.SerializedItem}, getAllFlags(t, store))
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 0)
+}
+
+func TestInitDiffReplacesAllDataIfAlwaysModified(t *testing.T) {
+	store, mockLog := buildTestStore(t, DataStore().InitDiff(InitDiffByHash).InitChunkSize(2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag0", 1))))
+
+	other, err := DataStore().PoolInterface(store.pool).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	version := 1
+	store.testTxHook = func() {
+		version++
+		_, err := other.Upsert(ldstoreimpl.Features(), "flag9", makeTestFlag("flag9", version).Item)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag0", 2))))
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Data was modified during 3 attempts at a diff-based Init")
+	assert.Equal(t, map[string][]byte{"flag0": makeTestFlag("flag0", 2).Item.SerializedItem}, getAllFlags(t, store))
+}
+
+func TestInitDiffReturnsReadError(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).InitDiff(InitDiffByVersion).InitChunkSize(2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag0", 1))))
+
+	faults.OnCommand("HSCAN").Times(1).Fail(nil)
+	assert.Equal(t, ldredistest.ErrInjectedFault, store.Init(makeTestFlagData(makeTestFlag("flag0", 2))))
+	assert.Equal(t, map[string][]byte{"flag0": makeTestFlag("flag0", 1).Item.SerializedItem}, getAllFlags(t, store))
+}
+
+func TestInitDiffReturnsErrorFromTransaction(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	var context subsystems.BasicClientContext
+	context.Logging.Loggers = mockLog.Loggers
+	// with a chunk size of 2, the HSET command fails inside the transaction on a Redis 3 server
+	store, err := DataStore().PoolInterface(redis3Pool{ldredistest.NewServer().NewPool()}).
+		InitDiff(InitDiffByVersion).InitChunkSize(2).Build(context)
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	err = store.Init(makeTestFlagData(makeTestFlag("flag1", 1), makeTestFlag("flag2", 1)))
+	assert.EqualError(t, err, "ERR wrong number of arguments for 'hset' command")
+	assert.Len(t, mockLog.GetOutput(ldlog.Info), 0)
+}
@@ -0,0 +1,167 @@
+package ldredis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+const (
+	initMetadataKey = "$initmeta"
+
+	initMetadataTimeField        = "initializedAt"
+	initMetadataSDKNameField     = "sdkName"
+	initMetadataSDKVersionField  = "sdkVersion"
+	initMetadataUserAgentField   = "userAgent"
+	initMetadataFingerprintField = "fingerprint"
+	initMetadataCountFieldPrefix = "count:"
+)
+
+// InitMetadata describes the last Init that wrote the data in Redis. See InitMetadataReader.
+type InitMetadata struct {
+	// InitializedAt is the time of the Init. It is zero if there is no metadata, either because
+	// the data has never been initialized or because it was initialized by an older version of
+	// this integration or by another SDK.
+	InitializedAt ldtime.UnixMillisecondTime
+	// SDKName and SDKVersion identify the SDK that called Init, such as "GoClient" and "7.0.0".
+	// They are empty if the store was not created by an SDK.
+	SDKName    string
+	SDKVersion string
+	// UserAgent is the complete User-Agent of the SDK that called Init. If the SDK is part of
+	// another application such as the Relay Proxy, this also identifies that application.
+	UserAgent string
+	// ItemCounts is the number of items of each kind, including deleted item placeholders, by the
+	// name of the kind, such as "features".
+	ItemCounts map[string]int
+	// Fingerprint is a hexadecimal SHA-256 hash of all of the keys and serialized items. Two
+	// Inits with the same data have the same fingerprint, regardless of the order of the items.
+	Fingerprint string
+}
+
+// InitMetadataReader provides information about the last Init, for health checks and diagnostic
+// tools:
+//
+//	metadata, err := store.(ldredis.InitMetadataReader).GetInitMetadata()
+type InitMetadataReader interface {
+	subsystems.PersistentDataStore
+
+	// GetInitMetadata returns the metadata that was written by the last Init. If there is no
+	// metadata, it returns a zero value and no error.
+	GetInitMetadata() (InitMetadata, error)
+}
+
+// sdkInfo identifies the SDK that is using a store.
+type sdkInfo struct {
+	name      string
+	version   string
+	userAgent string
+}
+
+// sdkInfoFromContext gets the SDK name and version from the User-Agent that the SDK uses for its
+// HTTP requests, such as "GoClient/7.0.0".
+func sdkInfoFromContext(clientContext subsystems.ClientContext) sdkInfo {
+	userAgent := clientContext.GetHTTP().DefaultHeaders.Get("User-Agent")
+	info := sdkInfo{userAgent: userAgent}
+	if fields := strings.Fields(userAgent); len(fields) > 0 {
+		info.name, info.version, _ = strings.Cut(fields[0], "/")
+	}
+	return info
+}
+
+// sendInitMetadata queues the commands that replace the metadata, as part of the Init transaction.
+func (store *redisDataStoreImpl) sendInitMetadata(c r.Conn, allData []ldstoretypes.SerializedCollection) {
+	key := store.initMetadataKey()
+	fields := []interface{}{
+		initMetadataTimeField, uint64(ldtime.UnixMillisNow()),
+		initMetadataFingerprintField, initFingerprint(allData),
+	}
+	for _, field := range []struct{ name, value string }{
+		{initMetadataSDKNameField, store.sdk.name},
+		{initMetadataSDKVersionField, store.sdk.version},
+		{initMetadataUserAgentField, store.sdk.userAgent},
+	} {
+		if field.value != "" {
+			fields = append(fields, field.name, field.value)
+		}
+	}
+	for _, coll := range allData {
+		fields = append(fields, initMetadataCountFieldPrefix+coll.Kind.GetName(), len(coll.Items))
+	}
+	_ = c.Send("DEL", key)
+	for i := 0; i < len(fields); i += 2 {
+		_ = c.Send("HSET", key, fields[i], fields[i+1]) // HSET only accepts one field before Redis 4.0
+	}
+}
+
+// GetInitMetadata returns the metadata that was written by the last Init. See InitMetadataReader.
+func (store *redisDataStoreImpl) GetInitMetadata() (InitMetadata, error) {
+	return guarded(store.breaker, func() (InitMetadata, error) {
+		return retried(store.retrier, store.getInitMetadata)
+	})
+}
+
+func (store *redisDataStoreImpl) getInitMetadata() (InitMetadata, error) {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	values, err := r.StringMap(c.Do("HGETALL", store.initMetadataKey()))
+	if err != nil || len(values) == 0 {
+		return InitMetadata{}, err
+	}
+	metadata := InitMetadata{
+		SDKName:     values[initMetadataSDKNameField],
+		SDKVersion:  values[initMetadataSDKVersionField],
+		UserAgent:   values[initMetadataUserAgentField],
+		Fingerprint: values[initMetadataFingerprintField],
+		ItemCounts:  make(map[string]int),
+	}
+	if t, err := strconv.ParseUint(values[initMetadataTimeField], 10, 64); err == nil {
+		metadata.InitializedAt = ldtime.UnixMillisecondTime(t)
+	}
+	for field, value := range values {
+		if strings.HasPrefix(field, initMetadataCountFieldPrefix) {
+			if n, err := strconv.Atoi(value); err == nil {
+				metadata.ItemCounts[strings.TrimPrefix(field, initMetadataCountFieldPrefix)] = n
+			}
+		}
+	}
+	return metadata, nil
+}
+
+func (store *redisDataStoreImpl) initMetadataKey() string {
+	return store.prefix + ":" + initMetadataKey
+}
+
+// initFingerprint computes a hash of all of the data that does not depend on the order of the
+// collections or items.
+func initFingerprint(allData []ldstoretypes.SerializedCollection) string {
+	colls := make([]ldstoretypes.SerializedCollection, len(allData))
+	copy(colls, allData)
+	sort.Slice(colls, func(i, j int) bool { return colls[i].Kind.GetName() < colls[j].Kind.GetName() })
+
+	h := sha256.New()
+	for _, coll := range colls {
+		items := make([]ldstoretypes.KeyedSerializedItemDescriptor, len(coll.Items))
+		copy(items, coll.Items)
+		sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+
+		// Each name and value is followed by a zero byte, which cannot occur in the JSON data, so
+		// that different data cannot produce the same input to the hash.
+		_, _ = h.Write([]byte(coll.Kind.GetName() + "\x00"))
+		for _, item := range items {
+			_, _ = h.Write([]byte(item.Key + "\x00"))
+			_, _ = h.Write(item.Item.SerializedItem)
+			_, _ = h.Write([]byte{0})
+		}
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
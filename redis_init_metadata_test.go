@@ -0,0 +1,113 @@
+package ldredis
+
+import (
+	"testing"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitWritesMetadata(t *testing.T) {
+	for _, mode := range []InitDiffMode{InitReplaceAll, InitDiffByVersion} {
+		t.Run(mode.String(), func(t *testing.T) {
+			store, _ := buildTestStore(t, DataStore().InitDiff(mode))
+			before := ldtime.UnixMillisNow()
+			require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag1", 1), makeTestFlag("flag2", 1))))
+			after := ldtime.UnixMillisNow()
+
+			metadata, err := store.GetInitMetadata()
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, metadata.InitializedAt, before)
+			assert.LessOrEqual(t, metadata.InitializedAt, after)
+			assert.Equal(t, "GoClient", metadata.SDKName)
+			assert.Equal(t, "7.0.0", metadata.SDKVersion)
+			assert.Equal(t, testUserAgent, metadata.UserAgent)
+			assert.Equal(t, map[string]int{"features": 2, "segments": 0}, metadata.ItemCounts)
+			assert.Len(t, metadata.Fingerprint, 64)
+		})
+	}
+}
+
+// redis3Pool makes HSET commands with more than one item fail, as they do before Redis 4.0.
+type redis3Pool struct {
+	ldredistest.ConnPool
+}
+
+type redis3Conn struct {
+	r.Conn
+}
+
+func (p redis3Pool) Get() r.Conn { return redis3Conn{p.ConnPool.Get()} }
+
+func (c redis3Conn) Send(commandName string, args ...interface{}) error {
+	if commandName == "HSET" && len(args) > 3 {
+		args = args[:1] // the server replies "wrong number of arguments"
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c redis3Conn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "HSET" && len(args) > 3 {
+		args = args[:1]
+	}
+	return c.Conn.Do(commandName, args...)
+}
+
+func TestInitMetadataIsWrittenWithRedis3(t *testing.T) {
+	store, err := DataStore().PoolInterface(redis3Pool{ldredistest.NewServer().NewPool()}).
+		Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	metadata, err := store.(InitMetadataReader).GetInitMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"features": 1, "segments": 0}, metadata.ItemCounts)
+}
+
+func TestInitMetadataIsEmptyBeforeInit(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore())
+	metadata, err := store.GetInitMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, InitMetadata{}, metadata)
+}
+
+func TestInitMetadataWithoutSDK(t *testing.T) {
+	store, err := DataStore().PoolInterface(ldredistest.NewServer().NewPool()).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+	require.NoError(t, store.Init(makeTestFlagData()))
+
+	metadata, err := store.(InitMetadataReader).GetInitMetadata()
+	require.NoError(t, err)
+	assert.NotEqual(t, ldtime.UnixMillisecondTime(0), metadata.InitializedAt)
+	assert.Equal(t, "", metadata.SDKName)
+	assert.Equal(t, "", metadata.UserAgent)
+}
+
+func TestInitFingerprint(t *testing.T) {
+	flag1, flag2 := makeTestFlag("flag1", 1), makeTestFlag("flag2", 1)
+	fingerprint := initFingerprint(makeTestFlagData(flag1, flag2))
+
+	assert.Equal(t, fingerprint, initFingerprint(makeTestFlagData(flag2, flag1)))
+	assert.NotEqual(t, fingerprint, initFingerprint(makeTestFlagData(flag1)))
+	assert.NotEqual(t, fingerprint, initFingerprint(makeTestFlagData(flag1, makeTestFlag("flag2", 2))))
+	assert.NotEqual(t, fingerprint, initFingerprint(makeTestFlagData(flag1, withSerializedItem(flag2, "{}"))))
+}
+
+func TestGetInitMetadataReturnsError(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, err := DataStore().PoolInterface(faults).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	faults.OnCommand("HGETALL").Times(1).Fail(nil)
+	_, err = store.(InitMetadataReader).GetInitMetadata()
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+}
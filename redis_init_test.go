@@ -0,0 +1,188 @@
+package ldredis
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeTestFlags(count int, version int) []ldstoretypes.KeyedSerializedItemDescriptor {
+	flags := make([]ldstoretypes.KeyedSerializedItemDescriptor, count)
+	for i := range flags {
+		flags[i] = makeTestFlag(fmt.Sprintf("flag%d", i), version)
+	}
+	return flags
+}
+
+func stagingKeyExists(t *testing.T, faults *ldredistest.FaultPool) bool {
+	c := faults.Get()
+	defer c.Close() //nolint:errcheck
+	values, err := r.Values(c.Do("SCAN", 0, "MATCH", DefaultPrefix+":"+stagingKeyPrefix+"*", "COUNT", 1000))
+	require.NoError(t, err)
+	keys, err := r.Strings(values[1], nil)
+	require.NoError(t, err)
+	return len(keys) > 0
+}
+
+func TestInitWritesOneItemPerCommandByDefault(t *testing.T) {
+	for _, mode := range []InitDiffMode{InitReplaceAll, InitDiffByVersion} {
+		store, err := DataStore().PoolInterface(redis3Pool{ldredistest.NewServer().NewPool()}).InitDiff(mode).
+			Build(subsystems.BasicClientContext{})
+		require.NoError(t, err)
+		defer store.Close() //nolint:errcheck
+
+		require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(10, 1)...)))
+		items, err := store.GetAll(ldstoreimpl.Features())
+		require.NoError(t, err)
+		assert.Len(t, items, 10)
+	}
+
+	store, err := DataStore().PoolInterface(redis3Pool{ldredistest.NewServer().NewPool()}).InitChunkSize(5).
+		Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+	assert.EqualError(t, store.Init(makeTestFlagData(makeTestFlags(3, 1)...)),
+		"ERR wrong number of arguments for 'hset' command")
+}
+
+func TestInitWritesLargeCollectionInChunks(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).InitChunkSize(3))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(10, 1)...)))
+
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 10)
+	assert.True(t, store.IsInitialized())
+	assert.False(t, stagingKeyExists(t, faults))
+
+	// replacing the data removes items that are no longer present
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(4, 2)...)))
+	items, err = store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 4)
+}
+
+func TestInitWritesSmallCollectionInTransaction(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).InitChunkSize(3))
+	faults.OnCommand("RENAME").Fail(nil)
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(3, 1)...)))
+
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 3)
+}
+
+func TestInitChunkFailureLeavesPreviousData(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).InitChunkSize(3))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+
+	faults.OnCommand("HSET").After(2).Times(1).Fail(nil)
+	err := store.Init(makeTestFlagData(makeTestFlags(10, 2)...))
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, makeTestFlag("flag1", 1).Item.SerializedItem, items[0].Item.SerializedItem)
+
+	// the partly written temporary key is replaced by the next Init
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(5, 3)...)))
+	items, err = store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 5)
+	assert.False(t, stagingKeyExists(t, faults))
+}
+
+func TestConcurrentInitsDoNotMixTheirData(t *testing.T) {
+	server := ldredistest.NewServer()
+	var stores []subsystems.PersistentDataStore
+	for i := 0; i < 2; i++ {
+		faults := ldredistest.NewFaultPool(server.NewPool())
+		faults.OnCommand("HSET").Delay(time.Millisecond) // so that the Inits overlap
+		store, err := DataStore().PoolInterface(faults).InitChunkSize(3).Build(subsystems.BasicClientContext{})
+		require.NoError(t, err)
+		defer store.Close() //nolint:errcheck
+		stores = append(stores, store)
+	}
+	payloads := [][]ldstoretypes.KeyedSerializedItemDescriptor{makeTestFlags(100, 1), makeTestFlags(70, 2)}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		var wg sync.WaitGroup
+		for i := range stores {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, stores[i].Init(makeTestFlagData(payloads[i]...)))
+			}(i)
+		}
+		wg.Wait()
+
+		items := getAllFlags(t, stores[0])
+		expected := make([]map[string][]byte, len(payloads))
+		for i, payload := range payloads {
+			expected[i] = make(map[string][]byte)
+			for _, item := range payload {
+				expected[i][item.Key] = item.Item.SerializedItem
+			}
+		}
+		require.Contains(t, expected, items, "the data must be exactly that of one of the Inits")
+	}
+	assert.False(t, stagingKeyExists(t, ldredistest.NewFaultPool(server.NewPool())))
+}
+
+func TestExecErrorReturnsFirstErrorInReply(t *testing.T) {
+	assert.NoError(t, execError(nil))
+	assert.NoError(t, execError([]interface{}{"OK", int64(1)}))
+	assert.Equal(t, r.Error("ERR no such key"),
+		execError([]interface{}{"OK", r.Error("ERR no such key"), r.Error("ERR other")}))
+}
+
+func TestStagingKeyExpiresIfInitDoesNotFinish(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).InitChunkSize(3))
+	faults.OnCommand("MULTI").Times(1).DropConnection()
+	assert.Error(t, store.Init(makeTestFlagData(makeTestFlags(10, 1)...)))
+	faults.Clear()
+	require.True(t, stagingKeyExists(t, faults), "the temporary key could not be deleted")
+
+	c := faults.Get()
+	defer c.Close() //nolint:errcheck
+	values, err := r.Values(c.Do("SCAN", 0, "MATCH", DefaultPrefix+":"+stagingKeyPrefix+"*"))
+	require.NoError(t, err)
+	keys, err := r.Strings(values[1], nil)
+	require.NoError(t, err)
+	ttl, err := r.Int64(c.Do("PTTL", keys[0]))
+	require.NoError(t, err)
+	assert.InDelta(t, stagingKeyTTL.Milliseconds(), ttl, float64(time.Minute.Milliseconds()))
+
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(10, 2)...)))
+	ttl, err = r.Int64(c.Do("PTTL", DefaultPrefix+":features"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl, "the data does not keep the expiry time of the temporary key")
+}
+
+func TestInitProgressIsLoggedAtInterval(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	p := newInitProgress(mockLog.Loggers, 12, 5)
+	for i := 0; i < 4; i++ {
+		p.add(3)
+	}
+	assert.Equal(t, []string{"Init has written 6 of 12 items", "Init has written 12 of 12 items"},
+		mockLog.GetOutput(ldlog.Info))
+}
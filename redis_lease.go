@@ -0,0 +1,367 @@
+package ldredis
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// MinWriterLeaseTTL is the shortest time to live that can be specified with StoreBuilder.WriterLease.
+const MinWriterLeaseTTL = time.Second
+
+// minWriterLeaseTTL is the minimum that is enforced. It is a variable so that tests can use a
+// shorter time to live.
+var minWriterLeaseTTL = MinWriterLeaseTTL //nolint:gochecknoglobals
+
+const (
+	leaseKey      = "$lease"
+	leaseTokenKey = "$leasetoken"
+	fenceKey      = "$fence"
+)
+
+// errFenceChanged is returned by a write whose transaction was aborted because another lease holder
+// wrote in the meantime. The write is attempted again, which finds out whether the lease was lost.
+var errFenceChanged = errors.New("another writer wrote to Redis during the transaction")
+
+// writerLease holds or tries to acquire the lease that allows a store to write. See
+// StoreBuilder.WriterLease.
+//
+// The lease key contains the fencing token and instance ID of the holder, and expires unless it is
+// renewed. The fence key contains the greatest token of any holder that has written. Every write
+// checks that it is not greater than its own token, and the first write of a new holder sets it in
+// the same transaction.
+//
+// Every store keeps a copy of the data it has been asked to write, whether or not it holds the
+// lease, so that when it acquires the lease it can write the latest data, including any updates
+// that it received while another store held the lease or while no store held it.
+type writerLease struct {
+	store       *redisDataStoreImpl
+	ttl         time.Duration
+	refreshLock sync.Mutex   // held while the lease is being renewed or acquired
+	writeLock   sync.RWMutex // held for reading during each write, and for writing while the data is replayed
+	lock        sync.Mutex
+	token       int64 // zero if the lease is not held
+	data        dataCopy
+	needsReplay bool // true if the lease has been acquired but the data has not yet been written
+	started     bool
+	closer      chan struct{}
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// newWriterLease returns nil if the lease is not enabled, or if the store does not write.
+func newWriterLease(store *redisDataStoreImpl, ttl time.Duration) *writerLease {
+	if ttl <= 0 || store.readOnly != ReadWrite {
+		return nil
+	}
+	return &writerLease{store: store, ttl: ttl, closer: make(chan struct{}), done: make(chan struct{})}
+}
+
+// start tries to acquire the lease, and then starts renewing it, or trying to acquire it, at
+// intervals.
+func (l *writerLease) start() {
+	if l == nil {
+		return
+	}
+	l.refresh()
+	l.lock.Lock()
+	l.started = true
+	l.lock.Unlock()
+	go l.run()
+}
+
+func (l *writerLease) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.closer:
+			return
+		case <-ticker.C:
+			l.refresh()
+		}
+	}
+}
+
+// refresh renews the lease if it is held, or tries to acquire it if it is not. After acquiring the
+// lease, it writes the latest data.
+func (l *writerLease) refresh() {
+	l.refreshLock.Lock()
+	defer l.refreshLock.Unlock()
+
+	token := l.currentToken()
+	var err error
+	if token != 0 {
+		var renewed bool
+		if renewed, err = l.renew(token); err == nil && !renewed {
+			l.lost(token)
+		}
+	} else {
+		if token, err = l.acquire(); err == nil && token != 0 {
+			l.lock.Lock()
+			l.token = token
+			l.needsReplay = l.data.inited
+			l.lock.Unlock()
+			l.store.loggers.Infof("Acquired the writer lease as %q with fencing token %d",
+				l.store.instanceID, token)
+		}
+	}
+	if err != nil {
+		l.store.loggers.Warnf("Unable to update the writer lease: %s", err)
+	}
+	l.replay()
+}
+
+// replay writes the latest data if the lease has been acquired since the data was last written.
+// Writes wait until it is done, so that it cannot overwrite a newer update; every write that
+// started earlier is included in the data.
+func (l *writerLease) replay() {
+	l.lock.Lock()
+	pending := l.token != 0 && l.needsReplay
+	l.lock.Unlock()
+	if !pending {
+		return
+	}
+
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+
+	l.lock.Lock()
+	token, needsReplay := l.token, l.needsReplay
+	var allData []ldstoretypes.SerializedCollection
+	if token != 0 && needsReplay {
+		allData = l.data.collections()
+	}
+	l.lock.Unlock()
+	if allData == nil {
+		return
+	}
+
+	if err := l.store.writeInit(allData, token); err != nil {
+		l.store.loggers.Warnf("Unable to write the latest data after acquiring the writer lease (%s); will retry", err)
+		return
+	}
+	l.lock.Lock()
+	if l.token == token {
+		l.needsReplay = false
+	}
+	l.lock.Unlock()
+	l.store.loggers.Info("Wrote the latest data after acquiring the writer lease")
+}
+
+// acquire sets the lease key if it does not exist, and returns the new fencing token if it did not.
+func (l *writerLease) acquire() (int64, error) {
+	c := l.store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	if exists, err := r.Bool(c.Do("EXISTS", l.store.leaseKey())); err != nil || exists {
+		return 0, err
+	}
+	token, err := r.Int64(c.Do("INCR", l.store.leaseTokenKey()))
+	if err != nil {
+		return 0, err
+	}
+	reply, err := c.Do("SET", l.store.leaseKey(), l.leaseValue(token), "NX", "PX", l.ttl.Milliseconds())
+	if err != nil || reply == nil {
+		return 0, err
+	}
+	return token, nil
+}
+
+// renew extends the lease, and returns false if it is no longer held by this store.
+func (l *writerLease) renew(token int64) (bool, error) {
+	c := l.store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	if _, err := c.Do("WATCH", l.store.leaseKey()); err != nil {
+		return false, err
+	}
+	defer c.Send("UNWATCH") // nolint:errcheck // this should always succeed
+	value, err := r.String(c.Do("GET", l.store.leaseKey()))
+	if err == r.ErrNil || (err == nil && value != l.leaseValue(token)) {
+		return false, nil
+	}
+	if err != nil { // COVERAGE: can't cause an error here in unit tests
+		return false, err
+	}
+	_ = c.Send("MULTI")
+	_ = c.Send("SET", l.store.leaseKey(), value, "XX", "PX", l.ttl.Milliseconds())
+	result, err := c.Do("EXEC")
+	if err != nil {
+		return false, err
+	}
+	return result != nil, nil
+}
+
+// lost records that the lease with the specified token is no longer held.
+func (l *writerLease) lost(token int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.token == token {
+		l.token = 0
+		l.store.loggers.Warnf("Lost the writer lease with fencing token %d; writes will be ignored", token)
+	}
+}
+
+func (l *writerLease) currentToken() int64 {
+	if l == nil {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.token
+}
+
+func (l *writerLease) leaseValue(token int64) string {
+	return strconv.FormatInt(token, 10) + ":" + l.store.instanceID
+}
+
+// stop stops renewing the lease and releases it, so that another store can acquire it immediately.
+func (l *writerLease) stop() {
+	if l == nil {
+		return
+	}
+	l.stopOnce.Do(func() {
+		close(l.closer)
+		l.lock.Lock()
+		started := l.started
+		l.lock.Unlock()
+		if started {
+			<-l.done
+		}
+		token := l.currentToken()
+		if token == 0 {
+			return
+		}
+		c := l.store.getConn()
+		defer c.Close() // nolint:errcheck
+		if _, err := c.Do("WATCH", l.store.leaseKey()); err != nil {
+			return
+		}
+		value, err := r.String(c.Do("GET", l.store.leaseKey()))
+		if err != nil || value != l.leaseValue(token) {
+			_, _ = c.Do("UNWATCH")
+			return
+		}
+		_ = c.Send("MULTI")
+		_ = c.Send("DEL", l.store.leaseKey())
+		_, _ = c.Do("EXEC")
+	})
+}
+
+// checkLease is called before a write operation. It records the data that is being written with
+// the record function, and tries to acquire the lease if it is not held. It returns the fencing
+// token to use for the write, and false if the write should be skipped because another store holds
+// the lease; the data will be written if this store acquires the lease later. The token is zero if
+// the lease is not enabled.
+//
+// If it returns true, endWrite must be called when the write is done.
+func (store *redisDataStoreImpl) checkLease(operation string, record func(*dataCopy)) (int64, bool) {
+	l := store.lease
+	if l == nil {
+		return 0, true
+	}
+	if l.currentToken() == 0 {
+		l.refresh() // the holder may have released the lease, or no store may have acquired it yet
+	}
+	l.writeLock.RLock()
+	l.lock.Lock()
+	record(&l.data)
+	token := l.token
+	l.lock.Unlock()
+	if token == 0 {
+		l.writeLock.RUnlock()
+		if store.loggers.IsDebugEnabled() { // COVERAGE: tests don't verify debug logging
+			store.loggers.Debugf("%s was deferred because another instance holds the writer lease", operation)
+		}
+		return 0, false
+	}
+	return token, true
+}
+
+// endWrite is called when a write for which checkLease returned true is done.
+func (l *writerLease) endWrite() {
+	if l != nil {
+		l.writeLock.RUnlock()
+	}
+}
+
+// holdsLease returns false if the writer lease is enabled but is held by another store.
+func (store *redisDataStoreImpl) holdsLease() bool {
+	return store.lease == nil || store.lease.currentToken() != 0
+}
+
+// acquireLease is like holdsLease, but first tries to acquire the lease if this store does not
+// hold it, in case no store holds it.
+func (store *redisDataStoreImpl) acquireLease() bool {
+	if !store.holdsLease() {
+		store.lease.refresh()
+	}
+	return store.holdsLease()
+}
+
+// watchFence starts watching the fence key, as part of a write with the specified fencing token,
+// and returns false if a holder with a greater token has already written. It does nothing if the
+// token is zero.
+//
+// It also returns the token that the write must record with sendFence. That is zero if the fence
+// key already contains the token, so that the fence key only changes when a new holder writes for
+// the first time; otherwise each write would abort any other write by the same holder that was
+// watching the fence key at the time, and an Init could be retried indefinitely.
+func (store *redisDataStoreImpl) watchFence(c r.Conn, token int64) (int64, bool, error) {
+	if token == 0 {
+		return 0, true, nil
+	}
+	if _, err := c.Do("WATCH", store.fenceKey()); err != nil {
+		return 0, false, err
+	}
+	fence, err := r.Int64(c.Do("GET", store.fenceKey()))
+	if err != nil && err != r.ErrNil {
+		return 0, false, err
+	}
+	if fence > token {
+		store.lease.lost(token)
+		return 0, false, nil
+	}
+	if fence == token {
+		return 0, true, nil
+	}
+	return token, true, nil
+}
+
+// sendFence queues the command that records the fencing token returned by watchFence, if any, as
+// part of a write transaction.
+func (store *redisDataStoreImpl) sendFence(c r.Conn, token int64) {
+	if token != 0 {
+		_ = c.Send("SET", store.fenceKey(), token)
+	}
+}
+
+// retryFenced calls a write function again as long as it fails with errFenceChanged. This ends when
+// the write succeeds or finds that a holder with a greater token has written, since the fence key
+// only changes when a new holder writes.
+func retryFenced(write func() error) error {
+	for {
+		if err := write(); err != errFenceChanged {
+			return err
+		}
+	}
+}
+
+func (store *redisDataStoreImpl) leaseKey() string {
+	return store.prefix + ":" + leaseKey
+}
+
+func (store *redisDataStoreImpl) leaseTokenKey() string {
+	return store.prefix + ":" + leaseTokenKey
+}
+
+func (store *redisDataStoreImpl) fenceKey() string {
+	return store.prefix + ":" + fenceKey
+}
@@ -0,0 +1,233 @@
+package ldredis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const leaseTestTTL = 60 * time.Millisecond
+
+// makeLeaseTestStore builds a store with a writer lease whose time to live is shorter than the
+// minimum that is normally allowed.
+func makeLeaseTestStore(
+	t *testing.T,
+	server *ldredistest.Server,
+	instanceID string,
+) (*redisDataStoreImpl, *ldlogtest.MockLog) {
+	defer func(ttl time.Duration) { minWriterLeaseTTL = ttl }(minWriterLeaseTTL)
+	minWriterLeaseTTL = leaseTestTTL
+	return buildTestStore(t, DataStore().PoolInterface(server.NewPool()).WriterLease(leaseTestTTL).InstanceID(instanceID))
+}
+
+func setFence(t *testing.T, server *ldredistest.Server, token int64) {
+	c := server.NewPool().Get()
+	defer c.Close() //nolint:errcheck
+	_, err := c.Do("SET", DefaultPrefix+":"+fenceKey, token)
+	require.NoError(t, err)
+}
+
+func TestOnlyLeaseHolderWrites(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, mockLog := makeLeaseTestStore(t, server, "a")
+	other, _ := makeLeaseTestStore(t, server, "b")
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, `Acquired the writer lease as "a" with fencing token 1`)
+	assert.Equal(t, int64(1), holder.lease.currentToken())
+	assert.Equal(t, int64(0), other.lease.currentToken())
+
+	require.NoError(t, other.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	assert.False(t, other.IsInitialized())
+	require.NoError(t, holder.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	assert.True(t, other.IsInitialized())
+
+	updated, err := other.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+	assert.False(t, updated)
+	updated, err = holder.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 3).Item)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	item, err := other.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 3).Item.SerializedItem, item.SerializedItem)
+}
+
+func TestLeaseIsRenewed(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	other, _ := makeLeaseTestStore(t, server, "b")
+
+	time.Sleep(3 * leaseTestTTL)
+	assert.Equal(t, int64(1), holder.lease.currentToken())
+	assert.Equal(t, int64(0), other.lease.currentToken())
+}
+
+func TestLeaseIsTakenOverWhenReleased(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	other, _ := makeLeaseTestStore(t, server, "b")
+
+	require.NoError(t, holder.Close())
+	require.Eventually(t, func() bool { return other.lease.currentToken() != 0 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(2), other.lease.currentToken())
+
+	require.NoError(t, other.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	assert.True(t, other.IsInitialized())
+}
+
+func TestLeaseIsTakenOverWhenExpired(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, mockLog := makeLeaseTestStore(t, server, "a")
+	other, _ := makeLeaseTestStore(t, server, "b")
+
+	// The holder stops renewing the lease without releasing it, as if it were paused.
+	close(holder.lease.closer)
+	<-holder.lease.done
+	holder.lease.closer = make(chan struct{}) // so that Close can close it again
+	require.Eventually(t, func() bool { return other.lease.currentToken() != 0 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, other.Init(makeTestFlagData(makeTestFlag("flag", 2))))
+
+	// The old holder still thinks it holds the lease, but its token is older than the one that was
+	// used for the last write.
+	assert.Equal(t, int64(1), holder.lease.currentToken())
+	require.NoError(t, holder.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Lost the writer lease with fencing token 1")
+	assert.Equal(t, int64(0), holder.lease.currentToken())
+
+	item, err := other.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 2).Item.SerializedItem, item.SerializedItem)
+}
+
+func TestUpsertChecksFenceInTransaction(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	require.NoError(t, holder.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	// Another holder writes after the Upsert has checked the fence but before its transaction.
+	holder.testTxHook = func() { setFence(t, server, 2) }
+	updated, err := holder.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, int64(0), holder.lease.currentToken())
+
+	item, err := holder.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 1).Item.SerializedItem, item.SerializedItem)
+}
+
+func TestWritesByHolderDoNotAbortEachOther(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	require.NoError(t, holder.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	// Another write by the same holder happens during each attempt at the Upsert. If it changed the
+	// fence key, the Upsert would be attempted again indefinitely.
+	attempts, inHook := 0, false
+	holder.testTxHook = func() {
+		if inHook || attempts >= 5 {
+			return
+		}
+		attempts++
+		inHook = true
+		_, err := holder.Upsert(ldstoreimpl.Segments(), "segment", makeTestFlag("segment", attempts).Item)
+		inHook = false
+		require.NoError(t, err)
+	}
+	updated, err := holder.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestNoLeaseForReadOnlyStore(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore().WriterLease(time.Minute).ReadOnly(ReadOnlyIgnore))
+	assert.Nil(t, store.lease)
+}
+
+func TestLeaseIsNotWaitedForIfStartupCheckFails(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	faults.OnCommand("PING").Fail(nil)
+	_, err := DataStore().PoolInterface(faults).WriterLease(time.Minute).StartupCheck(time.Second).
+		Build(subsystems.BasicClientContext{})
+	assert.Error(t, err)
+}
+
+func TestDeferredWritesAreWrittenWhenLeaseIsAcquired(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	require.NoError(t, holder.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+	other, mockLog := makeLeaseTestStore(t, server, "b")
+
+	require.NoError(t, other.Init(makeTestFlagData(makeTestFlag("flag1", 2))))
+	_, err := other.Upsert(ldstoreimpl.Features(), "flag2", makeTestFlag("flag2", 1).Item)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"flag1": makeTestFlag("flag1", 1).Item.SerializedItem}, getAllFlags(t, other))
+
+	require.NoError(t, holder.Close())
+	require.Eventually(t, func() bool {
+		return mockLog.HasMessageMatch(ldlog.Info, "Wrote the latest data after acquiring the writer lease")
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, map[string][]byte{
+		"flag1": makeTestFlag("flag1", 2).Item.SerializedItem,
+		"flag2": makeTestFlag("flag2", 1).Item.SerializedItem,
+	}, getAllFlags(t, other))
+}
+
+func TestWriteAcquiresLeaseThatIsNotHeld(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	other, _ := makeLeaseTestStore(t, server, "b")
+	require.NoError(t, holder.Close())
+
+	require.NoError(t, other.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	assert.NotEqual(t, int64(0), other.lease.currentToken())
+	assert.Equal(t, map[string][]byte{"flag": makeTestFlag("flag", 1).Item.SerializedItem}, getAllFlags(t, other))
+}
+
+func TestRestoreSnapshotFailsIfAnotherStoreHoldsLease(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	require.NoError(t, holder.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	snapshotter := startSnapshotter(t, Snapshots(t.TempDir()), holder)
+	path, err := snapshotter.TakeSnapshot()
+	require.NoError(t, err)
+
+	_, err = holder.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+	require.NoError(t, err)
+
+	// The data is not kept for later either, so it does not replace newer data when the other store
+	// acquires the lease.
+	other, mockLog := makeLeaseTestStore(t, server, "b")
+	assert.EqualError(t, RestoreSnapshot(other, path),
+		"snapshot was not written because another instance holds the writer lease")
+	require.NoError(t, holder.Close())
+	require.Eventually(t, func() bool { return other.lease.currentToken() != 0 }, time.Second, 5*time.Millisecond)
+	other.lease.refresh()
+	assert.False(t, mockLog.HasMessageMatch(ldlog.Info, "Wrote the latest data after acquiring the writer lease"))
+	assert.Equal(t, map[string][]byte{"flag": makeTestFlag("flag", 2).Item.SerializedItem}, getAllFlags(t, other))
+
+	require.NoError(t, RestoreSnapshot(other, path))
+	assert.Equal(t, map[string][]byte{"flag": makeTestFlag("flag", 1).Item.SerializedItem}, getAllFlags(t, other))
+}
+
+func TestRestoreSnapshotFailsIfLeaseIsLost(t *testing.T) {
+	server := ldredistest.NewServer()
+	holder, _ := makeLeaseTestStore(t, server, "a")
+	require.NoError(t, holder.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	snapshotter := startSnapshotter(t, Snapshots(t.TempDir()), holder)
+	path, err := snapshotter.TakeSnapshot()
+	require.NoError(t, err)
+
+	setFence(t, server, 2) // another holder has written, but this store has not found out yet
+	assert.EqualError(t, RestoreSnapshot(holder, path), "snapshot was not written because the writer lease "+
+		"was lost; it will be written if this instance acquires the lease again")
+}
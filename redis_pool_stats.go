@@ -0,0 +1,121 @@
+package ldredis
+
+import (
+	"fmt"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+// PoolStats describes the current state of a connection pool.
+type PoolStats struct {
+	// ActiveCount is the number of connections in the pool, both in use and idle.
+	ActiveCount int
+	// IdleCount is the number of idle connections in the pool.
+	IdleCount int
+	// WaitCount is the total number of times that a connection had to be waited for.
+	WaitCount int64
+	// WaitDuration is the total time spent waiting for connections.
+	WaitDuration time.Duration
+	// MaxActive is the maximum number of connections, or zero if there is no limit.
+	MaxActive int
+	// MaxIdle is the maximum number of idle connections.
+	MaxIdle int
+}
+
+// String returns a description of the statistics for logging.
+func (s PoolStats) String() string {
+	return fmt.Sprintf("active=%d idle=%d maxActive=%d maxIdle=%d waitCount=%d waitDuration=%s",
+		s.ActiveCount, s.IdleCount, s.MaxActive, s.MaxIdle, s.WaitCount, s.WaitDuration)
+}
+
+// PoolWithStats is an optional interface that a Pool can implement to report statistics. The
+// default pool, and any *redigo.Pool passed to StoreBuilder.Pool, provide statistics without
+// implementing this interface.
+type PoolWithStats interface {
+	Pool
+
+	// Stats returns the current state of the pool.
+	Stats() PoolStats
+}
+
+// getPoolStats returns the statistics for a pool, or false if the pool does not provide them.
+func getPoolStats(pool Pool) (PoolStats, bool) {
+	switch p := pool.(type) {
+	case *r.Pool:
+		stats := p.Stats()
+		return PoolStats{
+			ActiveCount:  stats.ActiveCount,
+			IdleCount:    stats.IdleCount,
+			WaitCount:    stats.WaitCount,
+			WaitDuration: stats.WaitDuration,
+			MaxActive:    p.MaxActive,
+			MaxIdle:      p.MaxIdle,
+		}, true
+	case *sharedPoolRef:
+		return getPoolStats(p.shared.pool)
+	case PoolWithStats:
+		return p.Stats(), true
+	default:
+		return PoolStats{}, false
+	}
+}
+
+func poolStatsValue(stats PoolStats) ldvalue.Value {
+	return ldvalue.ObjectBuild().
+		SetInt("activeCount", stats.ActiveCount).
+		SetInt("idleCount", stats.IdleCount).
+		SetFloat64("waitCount", float64(stats.WaitCount)).
+		SetFloat64("waitDurationMillis", float64(stats.WaitDuration.Milliseconds())).
+		SetInt("maxActive", stats.MaxActive).
+		SetInt("maxIdle", stats.MaxIdle).
+		Build()
+}
+
+// getPoolConn gets a connection from a pool. If that fails, and debug logging is enabled, it also
+// logs the pool statistics, since the cause could be that the pool is exhausted.
+func getPoolConn(pool Pool, loggers ldlog.Loggers) r.Conn {
+	c := pool.Get()
+	if err := c.Err(); err != nil && loggers.IsDebugEnabled() {
+		if stats, ok := getPoolStats(pool); ok {
+			loggers.Debugf("Unable to get a Redis connection (%s); pool stats: %s", err, stats)
+		}
+	}
+	return c
+}
+
+// logPoolStatsOnClose logs the pool statistics, if debug logging is enabled, when a store is closed.
+func logPoolStatsOnClose(pool Pool, loggers ldlog.Loggers) {
+	if loggers.IsDebugEnabled() {
+		if stats, ok := getPoolStats(pool); ok {
+			loggers.Debugf("Closing; pool stats: %s", stats)
+		}
+	}
+}
+
+// poolLimitsValue describes the configured limits of a pool, for DescribeConfiguration. If pool is
+// nil, the limits of the pool that will be created from the options are described.
+func poolLimitsValue(pool Pool, options poolLimitsOptions) (ldvalue.Value, bool) {
+	var maxActive, maxIdle int
+	var idleTimeout time.Duration
+	var wait bool
+	builder := ldvalue.ObjectBuild()
+	switch p := pool.(type) {
+	case nil:
+		maxActive, maxIdle, idleTimeout, wait = options.getMaxActive(), options.getMaxIdle(), options.getIdleTimeout(), true
+		builder.SetInt("minIdle", options.minIdle)
+	case *r.Pool:
+		maxActive, maxIdle, idleTimeout, wait = p.MaxActive, p.MaxIdle, p.IdleTimeout, p.Wait
+	default:
+		return ldvalue.Null(), false
+	}
+	return builder.
+		SetInt("maxActive", maxActive).
+		SetInt("maxIdle", maxIdle).
+		SetFloat64("idleTimeoutMillis", float64(idleTimeout.Milliseconds())).
+		SetBool("wait", wait).
+		Build(), true
+}
@@ -0,0 +1,103 @@
+package ldredis
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type poolWithFixedStats struct {
+	Pool
+	stats PoolStats
+}
+
+func (p poolWithFixedStats) Stats() PoolStats { return p.stats }
+
+func TestGetPoolStats(t *testing.T) {
+	t.Run("default pool", func(t *testing.T) {
+		pool := newPool(builderOptions{url: DefaultURL}, ldlog.NewDisabledLoggers())
+		defer pool.Close() //nolint:errcheck
+		stats, ok := getPoolStats(pool)
+		require.True(t, ok)
+		assert.Equal(t, PoolStats{MaxActive: 16, MaxIdle: 20}, stats)
+	})
+
+	t.Run("pool implementing PoolWithStats", func(t *testing.T) {
+		expected := PoolStats{ActiveCount: 3, IdleCount: 1, WaitCount: 2, WaitDuration: time.Second, MaxActive: 5}
+		stats, ok := getPoolStats(poolWithFixedStats{stats: expected})
+		require.True(t, ok)
+		assert.Equal(t, expected, stats)
+	})
+
+	t.Run("pool without statistics", func(t *testing.T) {
+		_, ok := getPoolStats(ldredistest.NewServer().NewPool())
+		assert.False(t, ok)
+	})
+
+	t.Run("shared pool", func(t *testing.T) {
+		expected := PoolStats{ActiveCount: 1}
+		shared := NewSharedPool(DataStore().PoolInterface(poolWithFixedStats{Pool: ldredistest.NewServer().NewPool(), stats: expected}))
+		defer shared.Close() //nolint:errcheck
+		store, err := DataStore().SharedPool(shared).Build(subsystems.BasicClientContext{})
+		require.NoError(t, err)
+		defer store.Close() //nolint:errcheck
+		stats, ok := store.(*redisDataStoreImpl).PoolStats()
+		require.True(t, ok)
+		assert.Equal(t, expected, stats)
+		stats, ok = shared.PoolStats()
+		require.True(t, ok)
+		assert.Equal(t, expected, stats)
+	})
+}
+
+func TestPoolStatsString(t *testing.T) {
+	stats := PoolStats{ActiveCount: 3, IdleCount: 1, WaitCount: 2, WaitDuration: time.Second, MaxActive: 16, MaxIdle: 20}
+	assert.Equal(t, "active=3 idle=1 maxActive=16 maxIdle=20 waitCount=2 waitDuration=1s", stats.String())
+}
+
+func TestBuilderReportsPoolStatsOfBuiltStore(t *testing.T) {
+	builder := BigSegmentStore()
+	_, ok := builder.PoolStats()
+	assert.False(t, ok)
+	assert.False(t, builder.DescribeConfiguration().GetByKey("poolStats").IsDefined())
+
+	store, err := builder.Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	stats, ok := builder.PoolStats()
+	require.True(t, ok)
+	assert.Equal(t, PoolStats{MaxActive: 16, MaxIdle: 20}, stats)
+	assert.Equal(t, ldvalue.Parse([]byte(`{
+		"activeCount":0,"idleCount":0,"waitCount":0,"waitDurationMillis":0,"maxActive":16,"maxIdle":20}`)),
+		builder.DescribeConfiguration().GetByKey("poolStats"))
+}
+
+func TestPoolStatsAreLoggedWhenConnectionIsUnavailable(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	mockLog.Loggers.SetMinLevel(ldlog.Debug)
+	pool := &r.Pool{
+		MaxActive: 1,
+		Dial:      func() (r.Conn, error) { return nil, errors.New("connection refused") },
+	}
+	store := newRedisDataStoreImpl(builderOptions{prefix: DefaultPrefix, pool: pool}, mockLog.Loggers)
+
+	assert.False(t, store.IsStoreAvailable())
+	mockLog.AssertMessageMatch(t, true, ldlog.Debug,
+		`Unable to get a Redis connection \(connection refused\); pool stats: active=0 idle=0 maxActive=1`)
+
+	_ = store.Close()
+	mockLog.AssertMessageMatch(t, true, ldlog.Debug, "Closing; pool stats: active=0")
+}
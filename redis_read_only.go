@@ -0,0 +1,50 @@
+package ldredis
+
+import (
+	"errors"
+)
+
+// ErrReadOnly is the error returned by Init and Upsert when the data store is in the
+// ReadOnlyReject mode. See StoreBuilder.ReadOnly.
+var ErrReadOnly = errors.New("data store is read-only; Redis write operation was not attempted")
+
+// ReadOnlyMode specifies whether the data store may write to Redis. See StoreBuilder.ReadOnly.
+type ReadOnlyMode int
+
+const (
+	// ReadWrite means that the data store writes to Redis normally. This is the default.
+	ReadWrite ReadOnlyMode = iota
+	// ReadOnlyReject means that Init and Upsert fail with ErrReadOnly without accessing Redis.
+	ReadOnlyReject
+	// ReadOnlyIgnore means that Init and Upsert do nothing except log a warning, and report success.
+	ReadOnlyIgnore
+)
+
+// String returns a description of the mode, such as "read-write".
+func (m ReadOnlyMode) String() string {
+	switch m {
+	case ReadWrite:
+		return "read-write"
+	case ReadOnlyReject:
+		return "read-only (reject writes)"
+	case ReadOnlyIgnore:
+		return "read-only (ignore writes)"
+	default:
+		return "unknown"
+	}
+}
+
+// checkWrite is called before a write operation. It returns true if the operation should proceed;
+// otherwise it returns the error, if any, that the operation should return instead.
+func (store *redisDataStoreImpl) checkWrite(operation string) (bool, error) {
+	switch store.readOnly {
+	case ReadOnlyReject:
+		store.loggers.Errorf("%s was rejected because the data store is read-only", operation)
+		return false, ErrReadOnly
+	case ReadOnlyIgnore:
+		store.loggers.Warnf("%s was ignored because the data store is read-only", operation)
+		return false, nil
+	default:
+		return true, nil
+	}
+}
@@ -0,0 +1,65 @@
+package ldredis
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyRejectMode(t *testing.T) {
+	server := ldredistest.NewServer()
+	store, mockLog := buildTestStore(t, DataStore().PoolInterface(server.NewPool()).ReadOnly(ReadOnlyReject))
+	writer, _ := buildTestStore(t, DataStore().PoolInterface(server.NewPool()))
+	defer mockLog.DumpIfTestFailed(t)
+	flag1 := makeTestFlag("flag1", 1)
+	require.NoError(t, writer.Init(makeTestFlagData(flag1)))
+
+	assert.Equal(t, ErrReadOnly, store.Init(makeTestFlagData()))
+	mockLog.AssertMessageMatch(t, true, ldlog.Error, "Init was rejected because the data store is read-only")
+
+	updated, err := store.Upsert(ldstoreimpl.Features(), "flag1", makeTestFlag("flag1", 2).Item)
+	assert.Equal(t, ErrReadOnly, err)
+	assert.False(t, updated)
+	mockLog.AssertMessageMatch(t, true, ldlog.Error, "Upsert was rejected because the data store is read-only")
+
+	item, err := store.Get(ldstoreimpl.Features(), "flag1")
+	require.NoError(t, err)
+	assert.Equal(t, flag1.Item.SerializedItem, item.SerializedItem)
+	assert.True(t, store.IsInitialized())
+}
+
+func TestReadOnlyIgnoreMode(t *testing.T) {
+	server := ldredistest.NewServer()
+	store, mockLog := buildTestStore(t, DataStore().PoolInterface(server.NewPool()).ReadOnly(ReadOnlyIgnore))
+	writer, _ := buildTestStore(t, DataStore().PoolInterface(server.NewPool()))
+	defer mockLog.DumpIfTestFailed(t)
+	flag1 := makeTestFlag("flag1", 1)
+
+	assert.NoError(t, store.Init(makeTestFlagData(flag1)))
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Init was ignored because the data store is read-only")
+	assert.False(t, store.IsInitialized())
+
+	require.NoError(t, writer.Init(makeTestFlagData(flag1)))
+	updated, err := store.Upsert(ldstoreimpl.Features(), "flag1", makeTestFlag("flag1", 2).Item)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Upsert was ignored because the data store is read-only")
+
+	item, err := store.Get(ldstoreimpl.Features(), "flag1")
+	require.NoError(t, err)
+	assert.Equal(t, flag1.Item.SerializedItem, item.SerializedItem)
+}
+
+func TestReadOnlyModeIsLoggedAtStartup(t *testing.T) {
+	_, mockLog := buildTestStore(t, DataStore().ReadOnly(ReadOnlyReject))
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, `Data store is read-only \(reject writes\)`)
+
+	assert.Equal(t, "read-write", ReadWrite.String())
+	assert.Equal(t, "read-only (ignore writes)", ReadOnlyIgnore.String())
+	assert.Equal(t, "unknown", ReadOnlyMode(99).String())
+}
@@ -0,0 +1,109 @@
+package ldredis
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+const (
+	// DefaultReadRetryInitialBackoff is the delay before the first retry that is used by
+	// StoreBuilder.ReadRetry if the specified initial backoff is zero or negative.
+	DefaultReadRetryInitialBackoff = 50 * time.Millisecond
+	// DefaultReadRetryMaxBackoff is the maximum delay between retries that is used by
+	// StoreBuilder.ReadRetry if the specified maximum backoff is zero or negative.
+	DefaultReadRetryMaxBackoff = time.Second
+)
+
+// retryableErrorPrefixes are the Redis error replies that indicate a temporary condition.
+var retryableErrorPrefixes = []string{
+	"LOADING",  // the server is loading its dataset into memory
+	"TRYAGAIN", // a multi-key operation hit a slot that is being migrated
+	"READONLY", // the connection is to a replica, usually because a failover is in progress
+}
+
+// IsRetryableError is the default test for whether a failed read should be retried, as described
+// in StoreBuilder.ReadRetry. It returns true for network errors, including a connection that was
+// closed unexpectedly, and for the Redis error replies LOADING, TRYAGAIN, and READONLY. It
+// returns false for all other errors, including ErrCircuitOpen.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var redisErr r.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range retryableErrorPrefixes {
+			if strings.HasPrefix(string(redisErr), prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+type readRetryOptions struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	isRetryable    func(error) bool
+}
+
+// readRetrier implements the behavior described in StoreBuilder.ReadRetry.
+type readRetrier struct {
+	options readRetryOptions
+	loggers ldlog.Loggers
+	sleep   func(time.Duration)
+}
+
+func newReadRetrier(options readRetryOptions, loggers ldlog.Loggers) *readRetrier {
+	if options.maxAttempts <= 1 {
+		return nil
+	}
+	if options.initialBackoff <= 0 {
+		options.initialBackoff = DefaultReadRetryInitialBackoff
+	}
+	if options.maxBackoff <= 0 {
+		options.maxBackoff = DefaultReadRetryMaxBackoff
+	}
+	if options.maxBackoff < options.initialBackoff {
+		options.maxBackoff = options.initialBackoff
+	}
+	if options.isRetryable == nil {
+		options.isRetryable = IsRetryableError
+	}
+	return &readRetrier{options: options, loggers: loggers, sleep: time.Sleep}
+}
+
+// retried performs a read operation, retrying it if it fails with a retryable error. Each attempt
+// is expected to obtain its own connection from the pool. If the retrier is nil, the operation is
+// performed only once.
+func retried[T any](rr *readRetrier, action func() (T, error)) (T, error) {
+	result, err := action()
+	if rr == nil {
+		return result, err
+	}
+	backoff := rr.options.initialBackoff
+	for attempt := 1; attempt < rr.options.maxAttempts && err != nil && rr.options.isRetryable(err); attempt++ {
+		if rr.loggers.IsDebugEnabled() { // COVERAGE: tests don't verify debug logging
+			rr.loggers.Debugf("Read failed (%s); retrying in %s (attempt %d of %d)",
+				err, backoff, attempt+1, rr.options.maxAttempts)
+		}
+		rr.sleep(backoff)
+		if backoff *= 2; backoff > rr.options.maxBackoff {
+			backoff = rr.options.maxBackoff
+		}
+		result, err = action()
+	}
+	return result, err
+}
@@ -0,0 +1,147 @@
+package ldredis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errLoading = r.Error("LOADING Redis is loading the dataset in memory")
+
+func TestIsRetryableError(t *testing.T) {
+	for _, p := range []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errLoading, true},
+		{r.Error("TRYAGAIN Multiple keys request during rehashing of slot"), true},
+		{r.Error("READONLY You can't write against a read only replica."), true},
+		{r.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, true},
+		{io.EOF, true},
+		{fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF), true},
+		{r.ErrPoolExhausted, false},
+		{ErrCircuitOpen, false},
+	} {
+		assert.Equal(t, p.retryable, IsRetryableError(p.err), "%v", p.err)
+	}
+}
+
+// recordRetrySleeps makes the store's read retries record each backoff delay instead of sleeping.
+func recordRetrySleeps(store *redisDataStoreImpl) *[]time.Duration {
+	var sleeps []time.Duration
+	store.retrier.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return &sleeps
+}
+
+func TestReadRetryIsDisabledByDefault(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults))
+	assert.Nil(t, store.retrier)
+
+	faults.OnCommand("HGET").Times(1).Fail(errLoading)
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, errLoading, err)
+}
+
+func TestReadRetrySucceedsAfterTransientErrors(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ReadRetry(4, 10*time.Millisecond, 25*time.Millisecond))
+	sleeps := recordRetrySleeps(store)
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+
+	faults.OnCommand("HGET").Times(3).Fail(errLoading)
+	item, err := store.Get(ldstoreimpl.Features(), "flag")
+	require.NoError(t, err)
+	assert.Equal(t, makeTestFlag("flag", 1).Item.SerializedItem, item.SerializedItem)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}, *sleeps)
+
+	*sleeps = nil
+	faults.OnCommand("HGETALL").Times(1).Fail(io.EOF)
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Len(t, *sleeps, 1)
+
+	*sleeps = nil
+	faults.OnCommand("EXISTS").Times(1).Fail(errLoading)
+	assert.True(t, store.IsInitialized())
+	assert.Len(t, *sleeps, 1)
+}
+
+func TestReadRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ReadRetry(3, 0, 0))
+	sleeps := recordRetrySleeps(store)
+
+	faults.OnCommand("HGET").Fail(errLoading)
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, errLoading, err)
+	assert.Equal(t, []time.Duration{DefaultReadRetryInitialBackoff, 2 * DefaultReadRetryInitialBackoff}, *sleeps)
+}
+
+func TestReadRetryDoesNotRetryOtherErrors(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ReadRetry(3, 0, 0))
+	sleeps := recordRetrySleeps(store)
+
+	faults.OnCommand("HGET").Times(1).Fail(nil)
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+	assert.Len(t, *sleeps, 0)
+}
+
+func TestReadRetryDoesNotApplyToWrites(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ReadRetry(3, 0, 0))
+	sleeps := recordRetrySleeps(store)
+
+	faults.OnCommand("EXEC").Times(1).Fail(io.EOF)
+	assert.Error(t, store.Init(makeTestFlagData()))
+	assert.Len(t, *sleeps, 0)
+}
+
+func TestReadRetryCondition(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ReadRetry(3, 0, 0).
+		ReadRetryCondition(func(err error) bool { return err == ldredistest.ErrInjectedFault }))
+	sleeps := recordRetrySleeps(store)
+
+	faults.OnCommand("HGET").Times(1).Fail(nil)
+	_, err := store.Get(ldstoreimpl.Features(), "flag")
+	assert.NoError(t, err)
+	assert.Len(t, *sleeps, 1)
+
+	faults.OnCommand("HGET").Times(1).Fail(errLoading)
+	_, err = store.Get(ldstoreimpl.Features(), "flag")
+	assert.Equal(t, errLoading, err)
+}
+
+func TestReadRetryForBigSegmentStore(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, err := BigSegmentStore().PoolInterface(faults).ReadRetry(2, time.Millisecond, 0).
+		Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	faults.OnCommand("SMEMBERS").Times(1).Fail(errLoading)
+	_, err = store.GetMembership("abc")
+	assert.NoError(t, err)
+
+	faults.OnCommand("GET").Times(1).Fail(errLoading)
+	_, err = store.GetMetadata()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,178 @@
+package ldredis
+
+import (
+	"errors"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+const (
+	// DefaultScanThreshold is the collection size above which GetAll uses HSCAN, if
+	// StoreBuilder.ScanLargeCollections is enabled with a threshold of zero.
+	DefaultScanThreshold = 10000
+
+	// DefaultScanBatchSize is the number of items requested by each HSCAN command, if
+	// StoreBuilder.ScanLargeCollections is enabled with a batch size of zero.
+	DefaultScanBatchSize = 1000
+)
+
+type scanOptions struct {
+	enabled   bool
+	threshold int
+	batchSize int
+}
+
+func (o scanOptions) getThreshold() int {
+	if o.threshold <= 0 {
+		return DefaultScanThreshold
+	}
+	return o.threshold
+}
+
+func (o scanOptions) getBatchSize() int {
+	if o.batchSize <= 0 {
+		return DefaultScanBatchSize
+	}
+	return o.batchSize
+}
+
+// IterableDataStore provides access to the items of a collection one at a time, so that a very
+// large collection does not have to be held in memory at once. To use it, build the store directly
+// rather than through the SDK:
+//
+//	store, err := ldredis.DataStore().Build(subsystems.BasicClientContext{})
+//	it := store.(ldredis.IterableDataStore).Iterate(ldstoreimpl.Segments())
+//	for it.Next() {
+//		item := it.Item()
+//		// ...
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type IterableDataStore interface {
+	subsystems.PersistentDataStore
+
+	// Iterate returns an iterator over all items of the specified kind, including deleted item
+	// placeholders. Items are read in batches with HSCAN; see StoreBuilder.ScanLargeCollections
+	// for the batch size.
+	Iterate(kind ldstoretypes.DataKind) *ItemIterator
+}
+
+// ItemIterator iterates over the items of one kind in the Redis data store. See
+// IterableDataStore.
+//
+// Each batch is read with a separate HSCAN command, and a connection is only held while a batch is
+// being read. As with any use of HSCAN, if the collection is modified during the iteration, items
+// that were added or removed in the meantime may or may not be returned, but every item that was
+// present for the whole iteration is returned exactly once.
+type ItemIterator struct {
+	store     *redisDataStoreImpl
+	key       string
+	batchSize int
+	cursor    string
+	started   bool
+	batch     []ldstoretypes.KeyedSerializedItemDescriptor
+	current   ldstoretypes.KeyedSerializedItemDescriptor
+	seen      map[string]struct{}
+	err       error
+}
+
+// Iterate returns an iterator over all items of the specified kind. See IterableDataStore.
+func (store *redisDataStoreImpl) Iterate(kind ldstoretypes.DataKind) *ItemIterator {
+	return &ItemIterator{
+		store:     store,
+		key:       store.featuresKey(kind),
+		batchSize: store.scan.getBatchSize(),
+		cursor:    "0",
+		seen:      make(map[string]struct{}),
+	}
+}
+
+// Next advances to the next item, and returns true if there is one. It returns false when there
+// are no more items or if an error occurred; use Err to distinguish between these.
+func (it *ItemIterator) Next() bool {
+	for len(it.batch) == 0 {
+		if it.err != nil || (it.started && it.cursor == "0") {
+			return false
+		}
+		it.err = it.readBatch()
+	}
+	it.current, it.batch = it.batch[0], it.batch[1:]
+	return true
+}
+
+// Item returns the current item. It is only valid after a call to Next has returned true.
+func (it *ItemIterator) Item() ldstoretypes.KeyedSerializedItemDescriptor {
+	return it.current
+}
+
+// Err returns the error, if any, that ended the iteration.
+func (it *ItemIterator) Err() error {
+	return it.err
+}
+
+func (it *ItemIterator) readBatch() error {
+	c := it.store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	reply, err := r.Values(c.Do("HSCAN", it.key, it.cursor, "COUNT", it.batchSize))
+	if err != nil {
+		return err
+	}
+	if len(reply) != 2 { // COVERAGE: can't cause an error here in unit tests
+		return errors.New("unexpected HSCAN reply")
+	}
+	cursor, err := r.String(reply[0], nil)
+	if err != nil { // COVERAGE: can't cause an error here in unit tests
+		return err
+	}
+	values, err := r.StringMap(reply[1], nil)
+	if err != nil { // COVERAGE: can't cause an error here in unit tests
+		return err
+	}
+	it.cursor, it.started = cursor, true
+	for k, v := range values {
+		// HSCAN can return an item more than once if the hash is resized during the iteration.
+		if _, ok := it.seen[k]; ok {
+			continue
+		}
+		it.seen[k] = struct{}{}
+		it.batch = append(it.batch, ldstoretypes.KeyedSerializedItemDescriptor{
+			Key:  k,
+			Item: ldstoretypes.SerializedItemDescriptor{Version: 0, SerializedItem: []byte(v)},
+		})
+	}
+	return nil
+}
+
+// shouldScan returns true if GetAll should use HSCAN rather than HGETALL for the specified hash.
+func (store *redisDataStoreImpl) shouldScan(key string) (bool, error) {
+	if !store.scan.enabled {
+		return false, nil
+	}
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+	size, err := r.Int(c.Do("HLEN", key))
+	if err != nil {
+		return false, err
+	}
+	return size > store.scan.getThreshold(), nil
+}
+
+// scanAll reads all items of a kind with HSCAN.
+func (store *redisDataStoreImpl) scanAll(
+	kind ldstoretypes.DataKind,
+) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	if store.loggers.IsDebugEnabled() { // COVERAGE: tests don't verify debug logging
+		store.loggers.Debugf(`Reading "%s" in batches of %d with HSCAN`, kind.GetName(), store.scan.getBatchSize())
+	}
+	var results []ldstoretypes.KeyedSerializedItemDescriptor
+	it := store.Iterate(kind)
+	for it.Next() {
+		results = append(results, it.Item())
+	}
+	return results, it.Err()
+}
@@ -0,0 +1,115 @@
+package ldredis
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func itemKeys(items []ldstoretypes.KeyedSerializedItemDescriptor) map[string]bool {
+	keys := make(map[string]bool, len(items))
+	for _, item := range items {
+		keys[item.Key] = true
+	}
+	return keys
+}
+
+func TestGetAllUsesHScanAboveThreshold(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ScanLargeCollections(5, 2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(7, 1)...)))
+	faults.OnCommand("HGETALL").Fail(nil)
+
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 7)
+	assert.Len(t, itemKeys(items), 7)
+	assert.True(t, itemKeys(items)["flag6"])
+}
+
+func TestGetAllUsesHGetAllAtOrBelowThreshold(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ScanLargeCollections(7, 2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(7, 1)...)))
+	faults.OnCommand("HSCAN").Fail(nil)
+
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 7)
+}
+
+func TestGetAllUsesHGetAllByDefault(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(3, 1)...)))
+	faults.OnCommand("HLEN").Fail(nil)
+	faults.OnCommand("HSCAN").Fail(nil)
+
+	items, err := store.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	assert.Len(t, items, 3)
+}
+
+func TestGetAllReturnsHScanError(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ScanLargeCollections(1, 2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(5, 1)...)))
+	faults.OnCommand("HSCAN").After(1).Fail(nil)
+
+	_, err := store.GetAll(ldstoreimpl.Features())
+	assert.ErrorIs(t, err, ldredistest.ErrInjectedFault)
+}
+
+func TestGetAllReturnsHLenError(t *testing.T) {
+	faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ScanLargeCollections(1, 2))
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(5, 1)...)))
+	faults.OnCommand("HLEN").Fail(nil)
+
+	_, err := store.GetAll(ldstoreimpl.Features())
+	assert.ErrorIs(t, err, ldredistest.ErrInjectedFault)
+}
+
+func TestItemIterator(t *testing.T) {
+	t.Run("returns every item once", func(t *testing.T) {
+		store, _ := buildTestStore(t, DataStore().ScanLargeCollections(0, 3))
+		require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(10, 1)...)))
+		it := IterableDataStore(store).Iterate(ldstoreimpl.Features())
+		var items []ldstoretypes.KeyedSerializedItemDescriptor
+		for it.Next() {
+			items = append(items, it.Item())
+		}
+		require.NoError(t, it.Err())
+		assert.Len(t, items, 10)
+		assert.Len(t, itemKeys(items), 10)
+		assert.False(t, it.Next())
+	})
+
+	t.Run("empty collection", func(t *testing.T) {
+		store, _ := buildTestStore(t, DataStore())
+		require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(0, 1)...)))
+		it := IterableDataStore(store).Iterate(ldstoreimpl.Features())
+		assert.False(t, it.Next())
+		assert.NoError(t, it.Err())
+	})
+
+	t.Run("stops at error", func(t *testing.T) {
+		faults := ldredistest.NewFaultPool(ldredistest.NewServer().NewPool())
+		store, _ := buildTestStore(t, DataStore().PoolInterface(faults).ScanLargeCollections(0, 3))
+		require.NoError(t, store.Init(makeTestFlagData(makeTestFlags(10, 1)...)))
+		faults.OnCommand("HSCAN").After(1).Fail(nil)
+		it := IterableDataStore(store).Iterate(ldstoreimpl.Features())
+		count := 0
+		for it.Next() {
+			count++
+		}
+		assert.Equal(t, 3, count)
+		assert.ErrorIs(t, it.Err(), ldredistest.ErrInjectedFault)
+		assert.False(t, it.Next())
+	})
+}
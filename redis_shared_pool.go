@@ -0,0 +1,143 @@
+package ldredis
+
+import (
+	"errors"
+	"sync"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// ErrSharedPoolClosed is the error returned when building a store that uses a SharedPool that has
+// already been closed.
+var ErrSharedPoolClosed = errors.New("shared Redis connection pool has been closed")
+
+// SharedPool is a Redis connection pool that several stores can use at once. This is useful when
+// one process uses many LaunchDarkly environments with different prefixes on the same Redis, since
+// otherwise each store has its own pool with up to 16 connections.
+//
+// Create a SharedPool with [NewSharedPool], and pass it to [StoreBuilder.SharedPool] for each store
+// that should use it:
+//
+//	sharedPool := ldredis.NewSharedPool(ldredis.DataStore().URL(myRedisURL))
+//	defer sharedPool.Close()
+//	config1.DataStore = ldcomponents.PersistentDataStore(
+//		ldredis.DataStore().SharedPool(sharedPool).Prefix("env1"))
+//	config2.DataStore = ldcomponents.PersistentDataStore(
+//		ldredis.DataStore().SharedPool(sharedPool).Prefix("env2"))
+//
+// The pool keeps a count of references to it. Each store that uses it holds a reference until the
+// store is closed, and the creator of the SharedPool holds a reference until it calls Close. The
+// underlying connections are closed when the last reference is released.
+type SharedPool struct {
+	options   builderOptions
+	lock      sync.Mutex
+	pool      Pool
+	idleConns *idleConnMaintainer
+	refCount  int
+	closed    bool
+}
+
+// NewSharedPool creates a SharedPool whose connection options are taken from a StoreBuilder.
+//
+// The options that are used are the ones that determine how to connect to Redis: URL, HostAndPort,
+// SocketPath, DialOptions, CredentialsProvider, and the TLS options. If the builder specifies a
+// pool with Pool or PoolInterface, that pool is shared instead. Other options, such as Prefix,
+// are ignored; specify those for each store.
+//
+// The connections are not created until a store that uses the pool is built.
+func NewSharedPool[T any](builder *StoreBuilder[T]) *SharedPool {
+	return &SharedPool{options: builder.builderOptions, pool: builder.builderOptions.pool, refCount: 1}
+}
+
+// Close releases the reference held by the creator of the SharedPool. The underlying connections
+// are closed once every store that uses the pool has also been closed. Calling Close more than
+// once has no additional effect.
+func (s *SharedPool) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.release()
+}
+
+// PoolStats returns the current state of the shared pool, or false if no store has used it yet or
+// the pool does not provide statistics. See PoolWithStats.
+func (s *SharedPool) PoolStats() (PoolStats, bool) {
+	s.lock.Lock()
+	pool := s.pool
+	s.lock.Unlock()
+	if pool == nil {
+		return PoolStats{}, false
+	}
+	return getPoolStats(pool)
+}
+
+// acquire returns a Pool for a store to use. Closing the returned Pool releases the store's
+// reference, rather than closing the underlying pool.
+func (s *SharedPool) acquire(loggers ldlog.Loggers) (Pool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.refCount == 0 {
+		return nil, ErrSharedPoolClosed
+	}
+	if s.pool == nil {
+		if err := s.options.validate(); err != nil {
+			return nil, err
+		}
+		loggers.SetPrefix("RedisSharedPool:")
+		logRedisURL(loggers, s.options.effectiveURL())
+		pool := newPool(s.options, loggers)
+		s.pool = pool
+		s.idleConns = startIdleConnMaintainer(pool, s.options.poolLimits.minIdle, minIdleCheckInterval, loggers)
+	}
+	s.refCount++
+	return &sharedPoolRef{shared: s}, nil
+}
+
+// release must be called with the lock held.
+func (s *SharedPool) release() error {
+	s.refCount--
+	if s.refCount > 0 || s.pool == nil {
+		return nil
+	}
+	s.idleConns.stop()
+	return s.pool.Close()
+}
+
+// sharedPoolRef is the Pool that a store uses when it has a SharedPool.
+type sharedPoolRef struct {
+	shared *SharedPool
+	closed bool
+}
+
+func (p *sharedPoolRef) Get() r.Conn {
+	return p.shared.pool.Get()
+}
+
+func (p *sharedPoolRef) Close() error {
+	p.shared.lock.Lock()
+	defer p.shared.lock.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return p.shared.release()
+}
+
+// withSharedPool returns a copy of the options in which the pool is a new reference to the
+// SharedPool, if one was specified.
+func (o builderOptions) withSharedPool(loggers ldlog.Loggers) (builderOptions, error) {
+	if o.sharedPool == nil {
+		return o, nil
+	}
+	pool, err := o.sharedPool.acquire(loggers)
+	if err != nil {
+		return o, err
+	}
+	o.pool = pool
+	return o, nil
+}
@@ -0,0 +1,105 @@
+package ldredis
+
+import (
+	"testing"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func isPoolOpen(pool Pool) bool {
+	c := pool.Get()
+	defer c.Close() //nolint:errcheck
+	_, err := c.Do("PING")
+	return err == nil
+}
+
+func TestSharedPoolIsUsedByMultipleStores(t *testing.T) {
+	server := ldredistest.NewServer()
+	shared := NewSharedPool(DataStore().PoolInterface(server.NewPool()))
+	defer shared.Close() //nolint:errcheck
+
+	store1, err := DataStore().SharedPool(shared).Prefix("env1").Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store1.Close() //nolint:errcheck
+	store2, err := DataStore().SharedPool(shared).Prefix("env2").Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store2.Close() //nolint:errcheck
+	bigSegments, err := BigSegmentStore().SharedPool(shared).Prefix("env1").Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer bigSegments.Close() //nolint:errcheck
+
+	require.NoError(t, store1.Init(makeTestFlagData(makeTestFlag("flag1", 1))))
+	require.NoError(t, store2.Init(makeTestFlagData(makeTestFlag("flag2", 1))))
+
+	items1, err := store1.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	require.Len(t, items1, 1)
+	assert.Equal(t, "flag1", items1[0].Key)
+	items2, err := store2.GetAll(ldstoreimpl.Features())
+	require.NoError(t, err)
+	require.Len(t, items2, 1)
+	assert.Equal(t, "flag2", items2[0].Key)
+	_, err = bigSegments.GetMetadata()
+	assert.NoError(t, err)
+}
+
+func TestSharedPoolIsClosedWhenLastReferenceIsReleased(t *testing.T) {
+	pool := ldredistest.NewServer().NewPool()
+	shared := NewSharedPool(BigSegmentStore().PoolInterface(pool))
+
+	store1, err := DataStore().SharedPool(shared).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	store2, err := DataStore().SharedPool(shared).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+
+	require.NoError(t, shared.Close())
+	assert.True(t, isPoolOpen(pool))
+	assert.True(t, store2.IsStoreAvailable())
+
+	require.NoError(t, store1.Close())
+	require.NoError(t, store1.Close()) // closing a store twice releases only one reference
+	assert.True(t, isPoolOpen(pool))
+	assert.True(t, store2.IsStoreAvailable())
+
+	require.NoError(t, store2.Close())
+	assert.False(t, isPoolOpen(pool))
+
+	_, err = DataStore().SharedPool(shared).Build(subsystems.BasicClientContext{})
+	assert.Equal(t, ErrSharedPoolClosed, err)
+	assert.NoError(t, shared.Close())
+}
+
+func TestSharedPoolCreatesDefaultPoolWhenFirstStoreIsBuilt(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	var context subsystems.BasicClientContext
+	context.Logging.Loggers = mockLog.Loggers
+
+	shared := NewSharedPool(DataStore().URL("redis://localhost:9999"))
+	_, ok := shared.PoolStats()
+	assert.False(t, ok) // the pool is not created until a store uses it
+
+	store1, err := DataStore().SharedPool(shared).Build(context)
+	require.NoError(t, err)
+	store2, err := BigSegmentStore().SharedPool(shared).Build(context)
+	require.NoError(t, err)
+
+	redisPool, ok := shared.pool.(*r.Pool)
+	require.True(t, ok)
+	assert.Len(t, mockLog.GetOutput(ldlog.Info), 1)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "RedisSharedPool: Using URL: redis://localhost:9999")
+
+	_ = store1.Close()
+	_ = store2.Close()
+	_ = shared.Close()
+	assert.EqualError(t, redisPool.Get().Err(), "redigo: get on closed pool")
+}
@@ -0,0 +1,448 @@
+package ldredis
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+const (
+	// DefaultSnapshotInterval is the default value for SnapshotterBuilder.Interval.
+	DefaultSnapshotInterval = time.Hour
+	// DefaultMaxSnapshots is the default maximum number of snapshots for SnapshotterBuilder.Retention.
+	DefaultMaxSnapshots = 24
+
+	snapshotFormatVersion = 1
+	snapshotFilePrefix    = "snapshot-"
+	snapshotFileExt       = ".json"
+	snapshotGzipExt       = ".gz"
+
+	// snapshotTimeFormat is used in file names, so that sorting the names sorts the snapshots by time.
+	snapshotTimeFormat = "20060102T150405.000Z"
+)
+
+// Snapshots returns a configurable builder for a Snapshotter, which periodically writes a copy of
+// all of the data in a data store to a file in the specified directory:
+//
+//	store, err := ldredis.DataStore().URL(myRedisURL).Build(subsystems.BasicClientContext{})
+//	snapshotter, err := ldredis.Snapshots("/var/backups/flags").Compress(true).Start(store)
+//	defer snapshotter.Close()
+//
+// A snapshot can be written back to a data store with [RestoreSnapshot].
+func Snapshots(dir string) *SnapshotterBuilder {
+	return &SnapshotterBuilder{
+		dir:          dir,
+		interval:     DefaultSnapshotInterval,
+		maxSnapshots: DefaultMaxSnapshots,
+		loggers:      ldlog.NewDefaultLoggers(),
+	}
+}
+
+// SnapshotterBuilder is a builder for configuring a Snapshotter. See [Snapshots].
+type SnapshotterBuilder struct {
+	dir          string
+	interval     time.Duration
+	compress     bool
+	maxSnapshots int
+	maxAge       time.Duration
+	loggers      ldlog.Loggers
+}
+
+// Interval specifies how often to write a snapshot. The default is DefaultSnapshotInterval.
+func (b *SnapshotterBuilder) Interval(interval time.Duration) *SnapshotterBuilder {
+	if interval <= 0 {
+		interval = DefaultSnapshotInterval
+	}
+	b.interval = interval
+	return b
+}
+
+// Compress specifies whether snapshot files should be compressed with gzip. The default is false.
+func (b *SnapshotterBuilder) Compress(compress bool) *SnapshotterBuilder {
+	b.compress = compress
+	return b
+}
+
+// Retention specifies which snapshots to keep. After each snapshot, the oldest snapshots are
+// deleted so that there are no more than maxSnapshots, and so that none is older than maxAge. The
+// latest snapshot is never deleted. Zero means no limit; the default is DefaultMaxSnapshots and
+// no age limit.
+func (b *SnapshotterBuilder) Retention(maxSnapshots int, maxAge time.Duration) *SnapshotterBuilder {
+	b.maxSnapshots = maxSnapshots
+	b.maxAge = maxAge
+	return b
+}
+
+// Loggers specifies where to log errors and other messages. The default is the same as the
+// default for the SDK, which writes to standard output.
+func (b *SnapshotterBuilder) Loggers(loggers ldlog.Loggers) *SnapshotterBuilder {
+	b.loggers = loggers
+	return b
+}
+
+// Start creates the snapshot directory if it does not exist, and starts writing snapshots of the
+// specified store at intervals. The first snapshot is written after one interval.
+func (b *SnapshotterBuilder) Start(store subsystems.PersistentDataStore) (*Snapshotter, error) {
+	if err := os.MkdirAll(b.dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &Snapshotter{
+		store:   store,
+		options: *b,
+		closer:  make(chan struct{}),
+		done:    make(chan struct{}),
+		loggers: b.loggers,
+	}
+	s.loggers.SetPrefix("RedisSnapshotter:")
+	go s.run()
+	return s, nil
+}
+
+// Snapshotter periodically writes a copy of the data in a data store to a file. See [Snapshots].
+type Snapshotter struct {
+	store     subsystems.PersistentDataStore
+	options   SnapshotterBuilder
+	lock      sync.Mutex // held while a snapshot is being written
+	closer    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	loggers   ldlog.Loggers
+}
+
+// SnapshotInfo describes a snapshot file. See [ListSnapshots].
+type SnapshotInfo struct {
+	// Path is the path of the file.
+	Path string
+	// CreatedAt is the time when the snapshot was taken.
+	CreatedAt time.Time
+	// Compressed is true if the file is compressed with gzip.
+	Compressed bool
+}
+
+// snapshotFile is the content of a snapshot file. Data maps each kind of data, such as "features",
+// to the serialized items of that kind.
+type snapshotFile struct {
+	FormatVersion int                                   `json:"formatVersion"`
+	CreatedAt     ldtime.UnixMillisecondTime            `json:"createdAt"`
+	Data          map[string]map[string]json.RawMessage `json:"data"`
+}
+
+// TakeSnapshot writes a snapshot immediately, and returns its path. It does nothing, and returns
+// an empty path, if the store has not been initialized.
+//
+// For a store created by DataStore, all kinds of data are read in a single transaction, so the
+// snapshot reflects the data at one point in time. For any other store, each kind of data is read
+// separately, so an update that is made while the snapshot is taken may only be partly included.
+func (s *Snapshotter) TakeSnapshot() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.store.IsInitialized() {
+		s.loggers.Info("Skipped a snapshot because the data store has not been initialized")
+		return "", nil
+	}
+	now := time.Now().UTC()
+	snapshot := snapshotFile{
+		FormatVersion: snapshotFormatVersion,
+		CreatedAt:     ldtime.UnixMillisecondTime(now.UnixMilli()),
+		Data:          make(map[string]map[string]json.RawMessage),
+	}
+	kinds := ldstoreimpl.AllKinds()
+	allItems, err := getAllKinds(s.store, kinds)
+	if err != nil {
+		return "", err
+	}
+	count := 0
+	for i, kind := range kinds {
+		serialized := make(map[string]json.RawMessage, len(allItems[i]))
+		for _, item := range allItems[i] {
+			serialized[item.Key] = item.Item.SerializedItem
+		}
+		snapshot.Data[kind.GetName()] = serialized
+		count += len(allItems[i])
+	}
+
+	name := snapshotFilePrefix + now.Format(snapshotTimeFormat) + snapshotFileExt
+	if s.options.compress {
+		name += snapshotGzipExt
+	}
+	path := filepath.Join(s.options.dir, name)
+	if err := writeSnapshotFile(path, snapshot, s.options.compress); err != nil {
+		return "", err
+	}
+	s.loggers.Infof("Wrote a snapshot of %d items to %s", count, path)
+	s.applyRetention(now)
+	return path, nil
+}
+
+// allKindsReader is implemented by data stores that can read several kinds of data at the same
+// point in time.
+type allKindsReader interface {
+	getAllKinds(kinds []ldstoretypes.DataKind) ([][]ldstoretypes.KeyedSerializedItemDescriptor, error)
+}
+
+// getAllKinds returns the items of each of the specified kinds, in the same order as the kinds.
+func getAllKinds(
+	store subsystems.PersistentDataStore,
+	kinds []ldstoretypes.DataKind,
+) ([][]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	if reader, ok := store.(allKindsReader); ok {
+		return reader.getAllKinds(kinds)
+	}
+	allItems := make([][]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(kinds))
+	for _, kind := range kinds {
+		items, err := store.GetAll(kind)
+		if err != nil {
+			return nil, err
+		}
+		allItems = append(allItems, items)
+	}
+	return allItems, nil
+}
+
+func (store *redisDataStoreImpl) getAllKinds(
+	kinds []ldstoretypes.DataKind,
+) ([][]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	return guarded(store.breaker, func() ([][]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+		return retried(store.retrier, func() ([][]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+			return store.readAllKinds(kinds)
+		})
+	})
+}
+
+// readAllKinds reads the items of each of the specified kinds in one transaction. Unlike GetAll,
+// it does not use HSCAN for large collections, since that would not be atomic.
+func (store *redisDataStoreImpl) readAllKinds(
+	kinds []ldstoretypes.DataKind,
+) ([][]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+
+	_ = c.Send("MULTI")
+	for _, kind := range kinds {
+		_ = c.Send("HGETALL", store.featuresKey(kind))
+	}
+	result, err := r.Values(c.Do("EXEC"))
+	if err == nil {
+		err = execError(result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	allItems := make([][]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(kinds))
+	for _, reply := range result {
+		values, err := r.StringMap(reply, nil)
+		if err != nil { // COVERAGE: can't cause an error here in unit tests
+			return nil, err
+		}
+		items := make([]ldstoretypes.KeyedSerializedItemDescriptor, 0, len(values))
+		for k, v := range values {
+			items = append(items, ldstoretypes.KeyedSerializedItemDescriptor{
+				Key:  k,
+				Item: ldstoretypes.SerializedItemDescriptor{Version: 0, SerializedItem: []byte(v)},
+			})
+		}
+		allItems = append(allItems, items)
+	}
+	return allItems, nil
+}
+
+// Close stops writing snapshots. It waits for a snapshot that is in progress to finish.
+func (s *Snapshotter) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closer)
+		<-s.done
+	})
+	return nil
+}
+
+func (s *Snapshotter) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.options.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closer:
+			return
+		case <-ticker.C:
+			if _, err := s.TakeSnapshot(); err != nil {
+				s.loggers.Errorf("Unable to write a snapshot: %s", err)
+			}
+		}
+	}
+}
+
+// applyRetention deletes the snapshots that are beyond the retention limits.
+func (s *Snapshotter) applyRetention(now time.Time) {
+	snapshots, err := ListSnapshots(s.options.dir)
+	if err != nil { // COVERAGE: can't cause an error here in unit tests
+		s.loggers.Warnf("Unable to list snapshots: %s", err)
+		return
+	}
+	if len(snapshots) == 0 { // the snapshot was deleted by something else
+		return
+	}
+	for i, snapshot := range snapshots[:len(snapshots)-1] { // the latest is always kept
+		tooMany := s.options.maxSnapshots > 0 && len(snapshots)-i > s.options.maxSnapshots
+		tooOld := s.options.maxAge > 0 && now.Sub(snapshot.CreatedAt) > s.options.maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(snapshot.Path); err != nil { // COVERAGE: can't cause an error here in unit tests
+			s.loggers.Warnf("Unable to delete old snapshot %s: %s", snapshot.Path, err)
+		}
+	}
+}
+
+// ListSnapshots returns the snapshots in a directory, from oldest to newest. Files whose names do
+// not match the names of snapshot files are ignored.
+func ListSnapshots(dir string) ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []SnapshotInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotFilePrefix) {
+			continue
+		}
+		info := SnapshotInfo{Path: filepath.Join(dir, name)}
+		timestamp := strings.TrimPrefix(name, snapshotFilePrefix)
+		if strings.HasSuffix(timestamp, snapshotGzipExt) {
+			info.Compressed = true
+			timestamp = strings.TrimSuffix(timestamp, snapshotGzipExt)
+		}
+		if !strings.HasSuffix(timestamp, snapshotFileExt) {
+			continue
+		}
+		timestamp = strings.TrimSuffix(timestamp, snapshotFileExt)
+		if info.CreatedAt, err = time.Parse(snapshotTimeFormat, timestamp); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, info)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt) })
+	return snapshots, nil
+}
+
+// RestoreSnapshot replaces all of the data in a data store with the data in a snapshot file, by
+// calling the store's Init method. The file may be compressed or not; this is determined from its
+// name.
+//
+// Kinds of data that are in the snapshot but are not known to this version of the SDK are ignored.
+// If the store was built with StoreBuilder.WriterLease and another instance holds the lease, an
+// error is returned and the store is not changed. If this store loses the lease while the snapshot
+// is being written, an error is also returned, but the store then keeps the data and writes it if
+// it acquires the lease again, as it does for any other write.
+func RestoreSnapshot(store subsystems.PersistentDataStore, path string) error {
+	snapshot, err := readSnapshotFile(path)
+	if err != nil {
+		return err
+	}
+	var allData []ldstoretypes.SerializedCollection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		coll := ldstoretypes.SerializedCollection{Kind: kind}
+		for key, data := range snapshot.Data[kind.GetName()] {
+			item, err := kind.Deserialize(data)
+			if err != nil {
+				return fmt.Errorf("snapshot contains invalid %s item %q: %w", kind.GetName(), key, err)
+			}
+			coll.Items = append(coll.Items, ldstoretypes.KeyedSerializedItemDescriptor{
+				Key: key,
+				Item: ldstoretypes.SerializedItemDescriptor{
+					Version:        item.Version,
+					Deleted:        item.Item == nil,
+					SerializedItem: data,
+				},
+			})
+		}
+		allData = append(allData, coll)
+	}
+	redisStore, _ := store.(*redisDataStoreImpl)
+	if redisStore != nil && !redisStore.acquireLease() {
+		return errors.New("snapshot was not written because another instance holds the writer lease")
+	}
+	if err := store.Init(allData); err != nil {
+		return err
+	}
+	if redisStore != nil && !redisStore.holdsLease() {
+		return errors.New("snapshot was not written because the writer lease was lost; " +
+			"it will be written if this instance acquires the lease again")
+	}
+	return nil
+}
+
+// writeSnapshotFile writes the snapshot to a temporary file that is then renamed, so that a
+// snapshot file is never incomplete.
+func writeSnapshotFile(path string, snapshot snapshotFile, compress bool) (err error) {
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+snapshotFilePrefix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+	if err = json.NewEncoder(w).Encode(snapshot); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err = gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func readSnapshotFile(path string) (snapshotFile, error) {
+	var snapshot snapshotFile
+	file, err := os.Open(path) //nolint:gosec // the path is specified by the application
+	if err != nil {
+		return snapshot, err
+	}
+	defer file.Close() // nolint:errcheck
+	var reader io.Reader = file
+	if strings.HasSuffix(path, snapshotGzipExt) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return snapshot, err
+		}
+		defer gz.Close() // nolint:errcheck
+		reader = gz
+	}
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return snapshot, err
+	}
+	if snapshot.FormatVersion != snapshotFormatVersion {
+		return snapshot, fmt.Errorf("unsupported snapshot format version %d", snapshot.FormatVersion)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,218 @@
+package ldredis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startSnapshotter(t *testing.T, builder *SnapshotterBuilder, store subsystems.PersistentDataStore) *Snapshotter {
+	snapshotter, err := builder.Loggers(ldlog.NewDisabledLoggers()).Start(store)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = snapshotter.Close() })
+	return snapshotter
+}
+
+func takeSnapshots(t *testing.T, snapshotter *Snapshotter, count int) []string {
+	var paths []string
+	for i := 0; i < count; i++ {
+		time.Sleep(2 * time.Millisecond) // so that each snapshot has a different name
+		path, err := snapshotter.TakeSnapshot()
+		require.NoError(t, err)
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func snapshotPaths(t *testing.T, dir string) []string {
+	snapshots, err := ListSnapshots(dir)
+	require.NoError(t, err)
+	var paths []string
+	for _, snapshot := range snapshots {
+		paths = append(paths, snapshot.Path)
+	}
+	return paths
+}
+
+func TestSnapshotIsWrittenAndRestored(t *testing.T) {
+	for _, p := range []struct {
+		name     string
+		compress bool
+	}{
+		{"uncompressed", false},
+		{"compressed", true},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			deleted := ldstoretypes.KeyedSerializedItemDescriptor{
+				Key: "deleted",
+				Item: ldstoretypes.SerializedItemDescriptor{
+					Version:        2,
+					Deleted:        true,
+					SerializedItem: ldstoreimpl.Features().Serialize(ldstoretypes.ItemDescriptor{Version: 2}),
+				},
+			}
+			store, _ := buildTestStore(t, DataStore())
+			require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag1", 1), makeTestFlag("flag2", 3), deleted)))
+
+			dir := filepath.Join(t.TempDir(), "snapshots")
+			snapshotter := startSnapshotter(t, Snapshots(dir).Compress(p.compress), store)
+			path, err := snapshotter.TakeSnapshot()
+			require.NoError(t, err)
+			assert.Equal(t, p.compress, strings.HasSuffix(path, ".json.gz"))
+
+			snapshots, err := ListSnapshots(dir)
+			require.NoError(t, err)
+			require.Len(t, snapshots, 1)
+			assert.Equal(t, path, snapshots[0].Path)
+			assert.Equal(t, p.compress, snapshots[0].Compressed)
+			assert.WithinDuration(t, time.Now(), snapshots[0].CreatedAt, time.Minute)
+
+			restored, _ := buildTestStore(t, DataStore())
+			require.NoError(t, RestoreSnapshot(restored, path))
+			assert.True(t, restored.IsInitialized())
+			assert.Equal(t, getAllFlags(t, store), getAllFlags(t, restored))
+		})
+	}
+}
+
+func TestSnapshotsAreWrittenAtIntervals(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore())
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	dir := t.TempDir()
+	startSnapshotter(t, Snapshots(dir).Interval(10*time.Millisecond), store)
+
+	require.Eventually(t, func() bool {
+		snapshots, err := ListSnapshots(dir)
+		return err == nil && len(snapshots) >= 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSnapshotIsTakenAtOnePointInTime(t *testing.T) {
+	server := ldredistest.NewServer()
+	faults := ldredistest.NewFaultPool(server.NewPool())
+	store, _ := buildTestStore(t, DataStore().PoolInterface(faults))
+	writer, _ := buildTestStore(t, DataStore().PoolInterface(server.NewPool()))
+	require.NoError(t, writer.Init([]ldstoretypes.SerializedCollection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedSerializedItemDescriptor{makeTestFlag("flag", 1)}},
+		{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedSerializedItemDescriptor{makeTestFlag("segment", 1)}},
+	}))
+
+	// Both kinds of data are updated after the flags have been requested, but before the segments
+	// have been requested.
+	faults.OnCommand("HGETALL").After(1).Times(1).Delay(50 * time.Millisecond)
+	updated := make(chan struct{})
+	go func() {
+		defer close(updated)
+		time.Sleep(10 * time.Millisecond)
+		_, err := writer.Upsert(ldstoreimpl.Features(), "flag", makeTestFlag("flag", 2).Item)
+		assert.NoError(t, err)
+		_, err = writer.Upsert(ldstoreimpl.Segments(), "segment", makeTestFlag("segment", 2).Item)
+		assert.NoError(t, err)
+	}()
+	snapshotter := startSnapshotter(t, Snapshots(t.TempDir()), store)
+	path, err := snapshotter.TakeSnapshot()
+	require.NoError(t, err)
+	<-updated
+
+	snapshot, err := readSnapshotFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(makeTestFlag("flag", 2).Item.SerializedItem), string(snapshot.Data["features"]["flag"]))
+	assert.Equal(t, string(makeTestFlag("segment", 2).Item.SerializedItem),
+		string(snapshot.Data["segments"]["segment"]))
+
+	faults.OnCommand("HGETALL").Times(1).Fail(nil)
+	_, err = snapshotter.TakeSnapshot()
+	assert.Equal(t, ldredistest.ErrInjectedFault, err)
+}
+
+func TestSnapshotIsSkippedIfStoreIsNotInitialized(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	dir := t.TempDir()
+	store, _ := buildTestStore(t, DataStore())
+	snapshotter, err := Snapshots(dir).Loggers(mockLog.Loggers).Start(store)
+	require.NoError(t, err)
+	defer snapshotter.Close() //nolint:errcheck
+
+	path, err := snapshotter.TakeSnapshot()
+	require.NoError(t, err)
+	assert.Equal(t, "", path)
+	assert.Len(t, snapshotPaths(t, dir), 0)
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Skipped a snapshot because the data store has not been initialized")
+}
+
+func TestSnapshotRetentionByCount(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore())
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0o600))
+	snapshotter := startSnapshotter(t, Snapshots(dir).Retention(2, 0), store)
+
+	paths := takeSnapshots(t, snapshotter, 4)
+	assert.Equal(t, paths[2:], snapshotPaths(t, dir))
+	_, err := os.Stat(filepath.Join(dir, "other.json"))
+	assert.NoError(t, err, "files that are not snapshots are not deleted")
+}
+
+func TestSnapshotRetentionByAge(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore())
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	dir := t.TempDir()
+	old := filepath.Join(dir, "snapshot-20000101T000000.000Z.json.gz")
+	require.NoError(t, os.WriteFile(old, nil, 0o600))
+	snapshotter := startSnapshotter(t, Snapshots(dir).Retention(0, time.Hour), store)
+
+	paths := takeSnapshots(t, snapshotter, 2)
+	assert.Equal(t, paths, snapshotPaths(t, dir))
+}
+
+func TestSnapshotRetentionAfterSnapshotsAreDeletedElsewhere(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore())
+	require.NoError(t, store.Init(makeTestFlagData(makeTestFlag("flag", 1))))
+	dir := t.TempDir()
+	mockLog := ldlogtest.NewMockLog()
+	snapshotter, err := Snapshots(dir).Retention(2, 0).Loggers(mockLog.Loggers).Start(store)
+	require.NoError(t, err)
+	defer snapshotter.Close() //nolint:errcheck
+	paths := takeSnapshots(t, snapshotter, 2)
+
+	require.NoError(t, os.Remove(paths[1]))
+	snapshotter.applyRetention(time.Now())
+	assert.Equal(t, paths[:1], snapshotPaths(t, dir), "the latest remaining snapshot is kept")
+
+	require.NoError(t, os.Remove(paths[0]))
+	snapshotter.applyRetention(time.Now())
+	assert.Len(t, snapshotPaths(t, dir), 0)
+	assert.Len(t, mockLog.GetOutput(ldlog.Warn), 0)
+}
+
+func TestRestoreSnapshotErrors(t *testing.T) {
+	store, _ := buildTestStore(t, DataStore())
+	dir := t.TempDir()
+
+	assert.Error(t, RestoreSnapshot(store, filepath.Join(dir, "missing.json")))
+
+	path := filepath.Join(dir, "snapshot-20000101T000000.000Z.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"formatVersion":2,"data":{}}`), 0o600))
+	assert.EqualError(t, RestoreSnapshot(store, path), "unsupported snapshot format version 2")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"formatVersion":1,"data":{"features":{"flag":"x"}}}`), 0o600))
+	err := RestoreSnapshot(store, path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `snapshot contains invalid features item "flag"`)
+
+	require.NoError(t, os.WriteFile(path+".gz", []byte("not gzip"), 0o600))
+	assert.Error(t, RestoreSnapshot(store, path+".gz"))
+	assert.False(t, store.IsInitialized())
+}
@@ -47,6 +47,10 @@ func clearTestData(prefix string) error {
 	}
 	defer client.Close()
 
+	return clearTestDataWithConn(client, prefix)
+}
+
+func clearTestDataWithConn(client r.Conn, prefix string) error {
 	cursor := 0
 	for {
 		resp, err := client.Do("SCAN", fmt.Sprintf("%d", cursor), "MATCH", prefix+":*")
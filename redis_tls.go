@@ -0,0 +1,159 @@
+package ldredis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+type tlsOptions struct {
+	caCertFile     string
+	clientCertFile string
+	clientKeyFile  string
+	serverName     string
+	minVersion     uint16
+}
+
+func (o tlsOptions) isEnabled() bool {
+	return o != tlsOptions{}
+}
+
+// withTLSScheme changes a redis:// URL to rediss://, so that Redigo will use TLS.
+func withTLSScheme(url string) string {
+	if strings.HasPrefix(url, "redis://") {
+		return "rediss://" + strings.TrimPrefix(url, "redis://")
+	}
+	return url
+}
+
+// tlsConfigLoader provides the TLS configuration for new connections. It reads the CA bundle and
+// client certificate from files, and reads them again whenever their modification time changes,
+// so that certificates can be rotated without restarting the SDK.
+type tlsConfigLoader struct {
+	options     tlsOptions
+	loggers     ldlog.Loggers
+	lock        sync.Mutex
+	rootCAs     *x509.CertPool
+	caModTime   time.Time
+	clientCert  *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newTLSConfigLoader(options tlsOptions, loggers ldlog.Loggers) *tlsConfigLoader {
+	return &tlsConfigLoader{options: options, loggers: loggers}
+}
+
+// config returns the TLS configuration to use for a new connection. If a file has changed but can't
+// be loaded, for instance because it is in the middle of being rewritten, the previously loaded
+// version is used and a warning is logged; an error is returned only if there is no previous
+// version.
+func (l *tlsConfigLoader) config() (*tls.Config, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.options.caCertFile != "" {
+		if err := l.loadCACerts(); err != nil {
+			if l.rootCAs == nil {
+				return nil, err
+			}
+			l.loggers.Warnf("Unable to reload TLS CA certificates; using previous version: %s", err)
+		}
+	}
+	if l.options.clientCertFile != "" || l.options.clientKeyFile != "" {
+		if err := l.loadClientCert(); err != nil {
+			if l.clientCert == nil {
+				return nil, err
+			}
+			l.loggers.Warnf("Unable to reload TLS client certificate; using previous version: %s", err)
+		}
+	}
+
+	config := &tls.Config{ //nolint:gosec // MinVersion defaults to the Go default unless specified
+		RootCAs:    l.rootCAs,
+		ServerName: l.options.serverName,
+		MinVersion: l.options.minVersion,
+	}
+	if l.clientCert != nil {
+		config.Certificates = []tls.Certificate{*l.clientCert}
+	}
+	return config, nil
+}
+
+// dialURL is equivalent to redigo.DialURL, but adds the current TLS configuration to the options.
+func (l *tlsConfigLoader) dialURL(url string, options ...r.DialOption) (r.Conn, error) {
+	config, err := l.config()
+	if err != nil {
+		return nil, err
+	}
+	allOptions := make([]r.DialOption, 0, len(options)+1)
+	allOptions = append(allOptions, options...)
+	allOptions = append(allOptions, r.DialTLSConfig(config))
+	return r.DialURL(url, allOptions...)
+}
+
+func (l *tlsConfigLoader) loadCACerts() error {
+	modTime, err := fileModTime(l.options.caCertFile)
+	if err != nil {
+		return err
+	}
+	if l.rootCAs != nil && modTime.Equal(l.caModTime) {
+		return nil
+	}
+	data, err := os.ReadFile(l.options.caCertFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no valid PEM certificates found in %s", l.options.caCertFile)
+	}
+	if l.rootCAs != nil {
+		l.loggers.Infof("Reloaded TLS CA certificates from %s", l.options.caCertFile)
+	}
+	l.rootCAs, l.caModTime = pool, modTime
+	return nil
+}
+
+func (l *tlsConfigLoader) loadClientCert() error {
+	if l.options.clientCertFile == "" || l.options.clientKeyFile == "" {
+		return errors.New("a TLS client certificate requires both a certificate file and a key file")
+	}
+	certModTime, err := fileModTime(l.options.clientCertFile)
+	if err != nil {
+		return err
+	}
+	keyModTime, err := fileModTime(l.options.clientKeyFile)
+	if err != nil {
+		return err
+	}
+	if l.clientCert != nil && certModTime.Equal(l.certModTime) && keyModTime.Equal(l.keyModTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(l.options.clientCertFile, l.options.clientKeyFile)
+	if err != nil {
+		return err
+	}
+	if l.clientCert != nil {
+		l.loggers.Infof("Reloaded TLS client certificate from %s", l.options.clientCertFile)
+	}
+	l.clientCert, l.certModTime, l.keyModTime = &cert, certModTime, keyModTime
+	return nil
+}
+
+func fileModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
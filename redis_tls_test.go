@@ -0,0 +1,224 @@
+package ldredis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func makeTestCertificate(t *testing.T, commonName string, issuer *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	parent, signer := template, key
+	if issuer == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+	} else {
+		parent, signer = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return &testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestWithTLSScheme(t *testing.T) {
+	assert.Equal(t, "rediss://host:6379", withTLSScheme("redis://host:6379"))
+	assert.Equal(t, "rediss://host:6379", withTLSScheme("rediss://host:6379"))
+
+	assert.Equal(t, DefaultURL, builderOptions{url: DefaultURL}.effectiveURL())
+	assert.Equal(t, "rediss://localhost:6379",
+		builderOptions{url: DefaultURL, tls: tlsOptions{serverName: "x"}}.effectiveURL())
+}
+
+func TestTLSConfigLoader(t *testing.T) {
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca := makeTestCertificate(t, "test-ca", nil)
+	client1 := makeTestCertificate(t, "client1", ca)
+	client2 := makeTestCertificate(t, "client2", ca)
+	modTime := time.Now().Add(-time.Minute)
+	writeTestFile(t, caFile, ca.certPEM, modTime)
+	writeTestFile(t, certFile, client1.certPEM, modTime)
+	writeTestFile(t, keyFile, client1.keyPEM, modTime)
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	loader := newTLSConfigLoader(tlsOptions{
+		caCertFile:     caFile,
+		clientCertFile: certFile,
+		clientKeyFile:  keyFile,
+		serverName:     "redis.example",
+		minVersion:     tls.VersionTLS13,
+	}, mockLog.Loggers)
+
+	config, err := loader.config()
+	require.NoError(t, err)
+	assert.Equal(t, "redis.example", config.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	require.NotNil(t, config.RootCAs)
+	require.Len(t, config.Certificates, 1)
+	assert.Equal(t, client1.cert.Raw, config.Certificates[0].Certificate[0])
+
+	t.Run("unchanged files are not reloaded", func(t *testing.T) {
+		config, err := loader.config()
+		require.NoError(t, err)
+		assert.Equal(t, client1.cert.Raw, config.Certificates[0].Certificate[0])
+		assert.Len(t, mockLog.GetOutput(ldlog.Info), 0)
+	})
+
+	t.Run("changed files are reloaded", func(t *testing.T) {
+		modTime = modTime.Add(time.Second)
+		writeTestFile(t, certFile, client2.certPEM, modTime)
+		writeTestFile(t, keyFile, client2.keyPEM, modTime)
+
+		config, err := loader.config()
+		require.NoError(t, err)
+		assert.Equal(t, client2.cert.Raw, config.Certificates[0].Certificate[0])
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, "Reloaded TLS client certificate")
+	})
+
+	t.Run("previous version is used if a file can't be loaded", func(t *testing.T) {
+		modTime = modTime.Add(time.Second)
+		writeTestFile(t, caFile, []byte("not a certificate"), modTime)
+		writeTestFile(t, keyFile, []byte("not a key"), modTime)
+
+		config, err := loader.config()
+		require.NoError(t, err)
+		assert.NotNil(t, config.RootCAs)
+		assert.Equal(t, client2.cert.Raw, config.Certificates[0].Certificate[0])
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Unable to reload TLS CA certificates")
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Unable to reload TLS client certificate")
+	})
+}
+
+func TestTLSConfigLoaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	badFile := filepath.Join(dir, "bad.pem")
+	writeTestFile(t, badFile, []byte("not a certificate"), time.Now())
+
+	for name, options := range map[string]tlsOptions{
+		"missing CA file":  {caCertFile: filepath.Join(dir, "missing.pem")},
+		"invalid CA file":  {caCertFile: badFile},
+		"missing key file": {clientCertFile: badFile},
+		"invalid key pair": {clientCertFile: badFile, clientKeyFile: badFile},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newTLSConfigLoader(options, ldlog.NewDisabledLoggers()).config()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTLSConfigLoaderDial(t *testing.T) {
+	dir := t.TempDir()
+	ca := makeTestCertificate(t, "test-ca", nil)
+	server := makeTestCertificate(t, "redis.example", ca)
+	client := makeTestCertificate(t, "client", ca)
+	otherCA := makeTestCertificate(t, "other-ca", nil)
+	for name, data := range map[string][]byte{
+		"ca.pem": ca.certPEM, "other-ca.pem": otherCA.certPEM, "cert.pem": client.certPEM, "key.pem": client.keyPEM,
+	} {
+		writeTestFile(t, filepath.Join(dir, name), data, time.Now())
+	}
+
+	serverCert, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{ //nolint:gosec
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	require.NoError(t, err)
+	defer listener.Close() //nolint:errcheck
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.(*tls.Conn).Handshake()
+			_ = c.Close()
+		}
+	}()
+	url := "redis://" + listener.Addr().(*net.TCPAddr).String()
+
+	dial := func(options tlsOptions) error {
+		builder := builderOptions{url: url, tls: options}
+		loader := newTLSConfigLoader(options, ldlog.NewDisabledLoggers())
+		c, err := loader.dialURL(builder.effectiveURL())
+		if err == nil {
+			_ = c.Close()
+		}
+		return err
+	}
+
+	assert.NoError(t, dial(tlsOptions{
+		caCertFile:     filepath.Join(dir, "ca.pem"),
+		clientCertFile: filepath.Join(dir, "cert.pem"),
+		clientKeyFile:  filepath.Join(dir, "key.pem"),
+		serverName:     "redis.example",
+	}))
+
+	assert.Error(t, dial(tlsOptions{
+		caCertFile:     filepath.Join(dir, "other-ca.pem"),
+		clientCertFile: filepath.Join(dir, "cert.pem"),
+		clientKeyFile:  filepath.Join(dir, "key.pem"),
+		serverName:     "redis.example",
+	}), "server certificate should not be trusted")
+
+	assert.Error(t, dial(tlsOptions{
+		caCertFile:     filepath.Join(dir, "ca.pem"),
+		clientCertFile: filepath.Join(dir, "cert.pem"),
+		clientKeyFile:  filepath.Join(dir, "key.pem"),
+		serverName:     "wrong.example",
+	}), "server name should not match")
+}
@@ -0,0 +1,60 @@
+package ldredis
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	r "github.com/gomodule/redigo/redis"
+)
+
+const unixSocketScheme = "unix"
+
+// unixSocketURL returns a unix:// URL for the specified socket path.
+func unixSocketURL(path string) string {
+	return (&url.URL{Scheme: unixSocketScheme, Path: path}).String()
+}
+
+func isUnixSocketURL(redisURL string) bool {
+	parsed, err := url.Parse(redisURL)
+	return err == nil && parsed.Scheme == unixSocketScheme
+}
+
+// dialUnixSocketURL is equivalent to redigo.DialURL, but for unix:// URLs, which Redigo does not
+// support. The URL has the form unix://[username:password@]/path/to/redis.sock[?db=number].
+//
+// As with redigo.DialURL, a username, password, or database number in the URL takes precedence over
+// the corresponding dial options.
+func dialUnixSocketURL(redisURL string, options ...r.DialOption) (r.Conn, error) {
+	parsed, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != unixSocketScheme {
+		return nil, fmt.Errorf("invalid Unix socket URL scheme: %s", parsed.Scheme)
+	}
+	if parsed.Path == "" {
+		return nil, errors.New("socket path is missing from Unix socket URL")
+	}
+
+	allOptions := make([]r.DialOption, 0, len(options)+3)
+	allOptions = append(allOptions, options...)
+	if parsed.User != nil {
+		if username := parsed.User.Username(); username != "" {
+			allOptions = append(allOptions, r.DialUsername(username))
+		}
+		if password, ok := parsed.User.Password(); ok {
+			allOptions = append(allOptions, r.DialPassword(password))
+		}
+	}
+	if dbParam := parsed.Query().Get("db"); dbParam != "" {
+		db, err := strconv.Atoi(dbParam)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("invalid database number in Unix socket URL: %s", dbParam)
+		}
+		allOptions = append(allOptions, r.DialDatabase(db))
+	}
+
+	return r.Dial("unix", parsed.Path, allOptions...)
+}
@@ -0,0 +1,124 @@
+package ldredis
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unixSocketTestServer accepts connections on a Unix socket, replies +OK to every command, and
+// records the commands it received.
+type unixSocketTestServer struct {
+	path     string
+	listener net.Listener
+	lock     sync.Mutex
+	commands [][]string
+}
+
+func newUnixSocketTestServer(t *testing.T) *unixSocketTestServer {
+	// Socket paths have a short length limit, so t.TempDir() may be too long on some platforms.
+	dir, err := os.MkdirTemp("", "ldredis")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	s := &unixSocketTestServer{path: filepath.Join(dir, "redis.sock")}
+	s.listener, err = net.Listen("unix", s.path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.listener.Close() })
+
+	go func() {
+		for {
+			nc, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc)
+		}
+	}()
+	return s
+}
+
+func (s *unixSocketTestServer) serve(nc net.Conn) {
+	defer nc.Close() //nolint:errcheck
+	// Commands are sent as arrays of bulk strings, so a Redigo connection can parse them as replies.
+	c := r.NewConn(nc, 0, 0)
+	for {
+		command, err := r.Strings(c.Receive())
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.commands = append(s.commands, command)
+		s.lock.Unlock()
+		if _, err := nc.Write([]byte("+OK\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func (s *unixSocketTestServer) receivedCommands() [][]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([][]string(nil), s.commands...)
+}
+
+func TestDialUnixSocketURL(t *testing.T) {
+	t.Run("path only", func(t *testing.T) {
+		s := newUnixSocketTestServer(t)
+		c, err := dialUnixSocketURL(unixSocketURL(s.path))
+		require.NoError(t, err)
+		_, err = c.Do("PING")
+		require.NoError(t, err)
+		_ = c.Close()
+		assert.Equal(t, [][]string{{"PING"}}, s.receivedCommands())
+	})
+
+	t.Run("credentials and database from URL", func(t *testing.T) {
+		s := newUnixSocketTestServer(t)
+		c, err := dialUnixSocketURL("unix://user:pass@" + s.path + "?db=3")
+		require.NoError(t, err)
+		_ = c.Close()
+		assert.Equal(t, [][]string{{"AUTH", "user", "pass"}, {"SELECT", "3"}}, s.receivedCommands())
+	})
+
+	t.Run("URL takes precedence over dial options", func(t *testing.T) {
+		s := newUnixSocketTestServer(t)
+		c, err := dialUnixSocketURL("unix://:url-pass@"+s.path,
+			r.DialPassword("option-pass"), r.DialDatabase(1))
+		require.NoError(t, err)
+		_ = c.Close()
+		assert.Equal(t, [][]string{{"AUTH", "url-pass"}, {"SELECT", "1"}}, s.receivedCommands())
+	})
+
+	t.Run("invalid URLs", func(t *testing.T) {
+		for _, badURL := range []string{"unix://", "unix:///tmp/redis.sock?db=x", "unix:///tmp/redis.sock?db=-1", "redis://localhost"} {
+			_, err := dialUnixSocketURL(badURL)
+			assert.Error(t, err, badURL)
+		}
+	})
+}
+
+func TestDataStoreCanUseUnixSocket(t *testing.T) {
+	s := newUnixSocketTestServer(t)
+	builder := DataStore().SocketPath(s.path).DialOptions(r.DialDatabase(2))
+	assert.Equal(t, "unix://"+s.path, builder.builderOptions.url)
+	assert.True(t, isUnixSocketURL(builder.builderOptions.url))
+	assert.False(t, isUnixSocketURL(DefaultURL))
+
+	store := newRedisDataStoreImpl(builder.builderOptions, ldlog.NewDisabledLoggers())
+	defer store.Close() //nolint:errcheck
+	c := store.getConn()
+	_, err := c.Do("PING")
+	require.NoError(t, err)
+	_ = c.Close()
+	assert.Equal(t, [][]string{{"SELECT", "2"}, {"PING"}}, s.receivedCommands())
+}
@@ -0,0 +1,81 @@
+package ldredis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// validate checks the options for problems that would make every store operation fail, so that
+// Build can return an error instead.
+func (o builderOptions) validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("invalid Redis configuration: "+format, args...)
+	}
+	if o.leaseTTL > 0 && o.leaseTTL < minWriterLeaseTTL {
+		return invalid("WriterLease time to live (%s) cannot be less than %s", o.leaseTTL, minWriterLeaseTTL)
+	}
+	if o.sharedPool != nil {
+		if o.pool != nil {
+			return invalid("Pool and PoolInterface cannot be used together with SharedPool")
+		}
+		return nil // the shared pool's options are validated when it is used
+	}
+	if o.pool != nil {
+		return nil // the other connection options are ignored
+	}
+	if err := validateURL(o.url); err != nil {
+		return invalid("URL is not valid: %s", err)
+	}
+	if isUnixSocketURL(o.url) && o.tls.isEnabled() {
+		return invalid("TLS options cannot be used with a Unix socket")
+	}
+	if (o.tls.clientCertFile == "") != (o.tls.clientKeyFile == "") {
+		return invalid("TLSClientCertFiles requires both a certificate file and a key file")
+	}
+	if minIdle := o.poolLimits.minIdle; minIdle > o.poolLimits.getMaxIdle() || minIdle > o.poolLimits.getMaxActive() {
+		return invalid("MinIdleConnections (%d) cannot be greater than the maximum number of connections (%d) "+
+			"or idle connections (%d)", minIdle, o.poolLimits.getMaxActive(), o.poolLimits.getMaxIdle())
+	}
+	if o.poolLimits.minIdle < 0 {
+		return invalid("MinIdleConnections cannot be negative")
+	}
+	return nil
+}
+
+// checkStartup verifies that a connection can be opened, which includes authentication if
+// credentials were specified, and that Redis responds to a PING, within the specified time.
+func checkStartup(pool Pool, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		c := pool.Get()
+		defer c.Close() //nolint:errcheck
+		_, err := c.Do("PING")
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			return fmt.Errorf("startup check could not connect to Redis: %w", err)
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("startup check could not connect to Redis within %s", timeout)
+	}
+}
+
+// prepare validates the options and returns the options to use for building a store.
+func (o builderOptions) prepare(loggers ldlog.Loggers) (builderOptions, error) {
+	if err := o.validate(); err != nil {
+		return o, err
+	}
+	return o.withSharedPool(loggers)
+}
+
+func (o builderOptions) runStartupCheck(pool Pool) error {
+	if o.startupCheckTimeout <= 0 {
+		return nil
+	}
+	return checkStartup(pool, o.startupCheckTimeout)
+}
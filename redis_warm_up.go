@@ -0,0 +1,117 @@
+package ldredis
+
+import (
+	"sync"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// minIdleCheckInterval is how often a pool is topped up to the minimum number of idle connections.
+const minIdleCheckInterval = 10 * time.Second
+
+// idleConnStartupTimeout is the longest time that building a store waits for the idle connections
+// to be opened. Since there is no dial timeout by default, opening connections to a Redis that
+// cannot be reached could otherwise block for as long as the operating system's connect timeout.
+// It is a variable so that tests can change it.
+var idleConnStartupTimeout = 2 * time.Second //nolint:gochecknoglobals
+
+// idleConnMaintainer keeps a minimum number of idle connections in a pool, so that requests do not
+// have to wait for a new connection to be dialed. See StoreBuilder.MinIdleConnections.
+type idleConnMaintainer struct {
+	pool     *r.Pool
+	minIdle  int
+	loggers  ldlog.Loggers
+	closer   chan struct{}
+	stopOnce sync.Once
+}
+
+// startIdleConnMaintainer starts a goroutine that fills the pool to the minimum number of idle
+// connections, and then keeps it filled until stop is called. It waits for the pool to be filled
+// the first time, but for no longer than idleConnStartupTimeout. It returns nil if minIdle is not
+// positive.
+func startIdleConnMaintainer(
+	pool *r.Pool,
+	minIdle int,
+	interval time.Duration,
+	loggers ldlog.Loggers,
+) *idleConnMaintainer {
+	if minIdle <= 0 {
+		return nil
+	}
+	m := &idleConnMaintainer{pool: pool, minIdle: minIdle, loggers: loggers, closer: make(chan struct{})}
+	filled := make(chan struct{})
+	go func() {
+		if n, err := m.fill(); err != nil {
+			loggers.Warnf("Unable to open %d connections at startup (%s); will retry in the background", minIdle, err)
+		} else {
+			loggers.Infof("Opened %d connections at startup", n)
+		}
+		close(filled)
+		m.run(interval)
+	}()
+	select {
+	case <-filled:
+	case <-time.After(idleConnStartupTimeout):
+		loggers.Warnf("Opening %d connections at startup is taking more than %s; continuing in the background",
+			minIdle, idleConnStartupTimeout)
+	}
+	return m
+}
+
+func (m *idleConnMaintainer) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.closer:
+			return
+		case <-ticker.C:
+			if n, err := m.fill(); err != nil {
+				m.loggers.Warnf("Unable to open idle connections: %s", err)
+			} else if n > 0 && m.loggers.IsDebugEnabled() {
+				m.loggers.Debugf("Opened %d idle connections", n)
+			}
+		}
+	}
+}
+
+// fill opens enough connections for the pool to have at least minIdle idle connections, as long as
+// that does not exceed the pool's limits, and returns the number of connections that were opened.
+func (m *idleConnMaintainer) fill() (int, error) {
+	stats := m.pool.Stats()
+	if stats.IdleCount >= m.minIdle {
+		return 0, nil
+	}
+	// Getting a connection takes an idle one if there is one, so to end up with minIdle idle
+	// connections we must get minIdle connections at once, of which some may already exist.
+	count := m.minIdle
+	if m.pool.MaxActive > 0 {
+		if available := m.pool.MaxActive - (stats.ActiveCount - stats.IdleCount); available < count {
+			count = available
+		}
+	}
+	conns := make([]r.Conn, 0, count)
+	defer func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}()
+	for i := 0; i < count; i++ {
+		c := m.pool.Get()
+		if err := c.Err(); err != nil {
+			_ = c.Close()
+			return 0, err
+		}
+		conns = append(conns, c)
+	}
+	return count - stats.IdleCount, nil
+}
+
+func (m *idleConnMaintainer) stop() {
+	if m != nil {
+		m.stopOnce.Do(func() { close(m.closer) })
+	}
+}
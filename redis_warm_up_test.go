@@ -0,0 +1,179 @@
+package ldredis
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	r "github.com/gomodule/redigo/redis"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-server-sdk-redis-redigo/v3/ldredistest"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type warmUpTestParams struct {
+	pool    *r.Pool
+	faults  *ldredistest.FaultPool
+	mockLog *ldlogtest.MockLog
+	lock    sync.Mutex
+	dials   int
+	dialErr error
+	blocked chan struct{} // if not nil, dialing waits until it is closed
+}
+
+// newWarmUpTestParams creates a Redigo pool whose connections are to an in-memory server.
+func newWarmUpTestParams(maxActive int) *warmUpTestParams {
+	p := &warmUpTestParams{
+		faults:  ldredistest.NewFaultPool(ldredistest.NewServer().NewPool()),
+		mockLog: ldlogtest.NewMockLog(),
+	}
+	p.pool = &r.Pool{
+		MaxActive: maxActive,
+		MaxIdle:   maxActive,
+		Wait:      true,
+		Dial: func() (r.Conn, error) {
+			p.lock.Lock()
+			blocked := p.blocked
+			p.lock.Unlock()
+			if blocked != nil {
+				<-blocked
+			}
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			if p.dialErr != nil {
+				return nil, p.dialErr
+			}
+			p.dials++
+			return p.faults.Get(), nil
+		},
+	}
+	return p
+}
+
+func (p *warmUpTestParams) dialCount() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.dials
+}
+
+func TestIdleConnectionsAreOpenedAtStartup(t *testing.T) {
+	p := newWarmUpTestParams(10)
+	defer p.mockLog.DumpIfTestFailed(t)
+	m := startIdleConnMaintainer(p.pool, 3, time.Hour, p.mockLog.Loggers)
+	defer m.stop()
+
+	assert.Equal(t, 3, p.pool.Stats().IdleCount)
+	assert.Equal(t, 3, p.dialCount())
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Info, "Opened 3 connections at startup")
+
+	n, err := m.fill()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 3, p.dialCount())
+}
+
+func TestIdleConnectionsAreReplenished(t *testing.T) {
+	p := newWarmUpTestParams(10)
+	m := startIdleConnMaintainer(p.pool, 3, 10*time.Millisecond, p.mockLog.Loggers)
+	defer m.stop()
+
+	// A connection that has an error is discarded instead of being returned to the pool.
+	p.faults.OnCommand("GET").Times(1).DropConnection()
+	c := p.pool.Get()
+	_, _ = c.Do("GET", "key")
+	_ = c.Close()
+	assert.Equal(t, 2, p.pool.Stats().IdleCount)
+
+	require.Eventually(t, func() bool { return p.pool.Stats().IdleCount == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 4, p.dialCount())
+
+	m.stop()
+	m.stop() // stopping twice is harmless
+}
+
+func TestIdleConnectionsDoNotExceedPoolLimit(t *testing.T) {
+	p := newWarmUpTestParams(3)
+	inUse1, inUse2 := p.pool.Get(), p.pool.Get()
+	require.NoError(t, inUse1.Err())
+	require.NoError(t, inUse2.Err())
+
+	m := startIdleConnMaintainer(p.pool, 3, time.Hour, p.mockLog.Loggers)
+	defer m.stop()
+	assert.Equal(t, 1, p.pool.Stats().IdleCount)
+	assert.Equal(t, 3, p.pool.Stats().ActiveCount)
+
+	_ = inUse1.Close()
+	_ = inUse2.Close()
+}
+
+func TestIdleConnectionsAreRetriedAfterStartupFailure(t *testing.T) {
+	p := newWarmUpTestParams(10)
+	defer p.mockLog.DumpIfTestFailed(t)
+	p.dialErr = errors.New("connection refused")
+	m := startIdleConnMaintainer(p.pool, 2, 10*time.Millisecond, p.mockLog.Loggers)
+	defer m.stop()
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		`Unable to open 2 connections at startup \(connection refused\); will retry in the background`)
+
+	p.lock.Lock()
+	p.dialErr = nil
+	p.lock.Unlock()
+	require.Eventually(t, func() bool { return p.pool.Stats().IdleCount == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestIdleConnectionsDoNotBlockStartupForLong(t *testing.T) {
+	defer func(timeout time.Duration) { idleConnStartupTimeout = timeout }(idleConnStartupTimeout)
+	idleConnStartupTimeout = 20 * time.Millisecond
+	p := newWarmUpTestParams(10)
+	defer p.mockLog.DumpIfTestFailed(t)
+	p.blocked = make(chan struct{})
+
+	started := time.Now()
+	m := startIdleConnMaintainer(p.pool, 2, time.Hour, p.mockLog.Loggers)
+	defer m.stop()
+	assert.Less(t, time.Since(started), time.Second)
+	p.mockLog.AssertMessageMatch(t, true, ldlog.Warn,
+		"Opening 2 connections at startup is taking more than 20ms; continuing in the background")
+
+	close(p.blocked)
+	require.Eventually(t, func() bool { return p.pool.Stats().IdleCount == 2 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return p.mockLog.HasMessageMatch(ldlog.Info, "Opened 2 connections at startup")
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestNoIdleConnectionsAreOpenedByDefault(t *testing.T) {
+	p := newWarmUpTestParams(10)
+	assert.Nil(t, startIdleConnMaintainer(p.pool, 0, time.Hour, p.mockLog.Loggers))
+	assert.Equal(t, 0, p.dialCount())
+}
+
+func TestDataStoreOpensIdleConnectionsWhenBuilt(t *testing.T) {
+	s := newUnixSocketTestServer(t)
+	builder := DataStore().SocketPath(s.path).MinIdleConnections(2)
+	store, err := builder.Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	defer store.Close() //nolint:errcheck
+
+	stats, ok := builder.PoolStats()
+	require.True(t, ok)
+	assert.Equal(t, 2, stats.IdleCount)
+}
+
+func TestMinIdleConnectionsIsValidated(t *testing.T) {
+	_, err := DataStore().MinIdleConnections(17).Build(subsystems.BasicClientContext{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MinIdleConnections (17) cannot be greater than the maximum number of connections (16)")
+
+	_, err = DataStore().PoolLimits(50, 10, 0).MinIdleConnections(11).Build(subsystems.BasicClientContext{})
+	assert.Error(t, err)
+
+	_, err = DataStore().MinIdleConnections(-1).Build(subsystems.BasicClientContext{})
+	assert.Error(t, err)
+}